@@ -0,0 +1,67 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// requireAdmin only allows requests carrying the admin
+// token as a bearer token. When no admin token is
+// configured, the endpoint doesn't exist.
+func (s *Server) requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			s.sendJSONError(r, w, &apiError{
+				status: http.StatusUnauthorized,
+				code:   codeUnauthorized,
+				msg:    "invalid admin token",
+			})
+			return
+		}
+		h(w, r)
+	}
+}
+
+type cacheResp struct {
+	Stats   cacheStats       `json:"stats"`
+	Entries []cacheEntryInfo `json:"entries"`
+}
+
+type purgeResp struct {
+	Purged int `json:"purged"`
+}
+
+// CacheHandler reports the contents of the tree cache on GET.
+// On DELETE it removes the tree given by the root
+// query param, or every tree if no root is given.
+func (s *Server) CacheHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	switch r.Method {
+	case http.MethodGet:
+		s.sendJSON(r, w, cacheResp{
+			Stats:   s.tlru.Stats(),
+			Entries: s.tlru.Entries(),
+		})
+	case http.MethodDelete:
+		root := r.URL.Query().Get("root")
+		if root == "" {
+			s.sendJSON(r, w, purgeResp{Purged: s.tlru.Purge()})
+			return
+		}
+		var n int
+		if s.tlru.Remove(common.HexToHash(root)) {
+			n = 1
+		}
+		s.sendJSON(r, w, purgeResp{Purged: n})
+	default:
+		http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
+	}
+}
@@ -1,61 +1,161 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/contextwtf/lanyard/api/tracing"
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/contextwtf/lanyard/merkle"
 
-	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/rs/cors"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
 	"github.com/rs/zerolog/log"
-	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"golang.org/x/sync/singleflight"
 )
 
 type Server struct {
-	db   *pgxpool.Pool
-	tlru *lru.Cache[common.Hash, cachedTree]
+	db         *tracing.DB
+	tlru       *treeCache
+	treeBuilds singleflight.Group
+	adminToken string
+
+	requireKeys bool
+	lookupKey   func(ctx context.Context, hash []byte) (APIKey, error)
+
+	writeLimit RateLimit
+	readLimit  RateLimit
+	limiter    RateLimiter
+
+	limits  Limits
+	proxies TrustedProxies
+
+	metrics *metrics
+
+	// loadTree reads a tree and its persisted levels from the db.
+	// It is a field so tests can count db reads.
+	loadTree func(ctx context.Context, root []byte) (getTreeResp, merkle.Tree, error)
+
+	// checkTree returns when the cached tree with root was
+	// inserted, or pgx.ErrNoRows if it was deleted or expired,
+	// every treeCheck. See WithTreeCheckInterval.
+	checkTree func(ctx context.Context, root []byte) (time.Time, error)
+	treeCheck time.Duration
 }
 
-func New(db *pgxpool.Pool) *Server {
-	l, err := lru.New[common.Hash, cachedTree](1000)
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed to create lru cache")
+type Option func(*Server)
+
+// WithTreeCache bounds the in-memory tree cache by the
+// approximate number of bytes used by the cached trees and by
+// the number of cached trees. A value of 0 disables that bound.
+func WithTreeCache(maxBytes int64, maxEntries int) Option {
+	return func(s *Server) {
+		s.tlru = newTreeCache(maxBytes, maxEntries)
 	}
-	return &Server{
-		db:   db,
-		tlru: l,
+}
+
+// WithAdminToken enables the /admin endpoints for
+// requests bearing the token. The endpoints
+// are disabled when the token is empty.
+func WithAdminToken(token string) Option {
+	return func(s *Server) {
+		s.adminToken = token
 	}
 }
 
-func (s *Server) Handler(env, gitSha string) http.Handler {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/api/v1/tree", s.TreeHandler)
-	mux.HandleFunc("/api/v1/proof", s.GetProof)
-	mux.HandleFunc("/api/v1/root", s.GetRoot)
-	mux.HandleFunc("/api/v1/roots", s.GetRoot)
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+// WithTreeCheckInterval sets how often a cached tree is
+// checked against the db, so that a tree deleted by another
+// server is served from the cache for at most d. A value of 0
+// disables the check.
+func WithTreeCheckInterval(d time.Duration) Option {
+	return func(s *Server) {
+		s.treeCheck = d
+	}
+}
+
+const (
+	DefaultTreeCacheBytes   = 1 << 30
+	DefaultTreeCacheEntries = 1000
+	DefaultTreeCheck        = time.Minute
+)
+
+// Uses a tree cache of [DefaultTreeCacheBytes] and
+// [DefaultTreeCacheEntries] unless specified
+// using [WithTreeCache], and checks cached trees
+// every [DefaultTreeCheck].
+func New(db *pgxpool.Pool, opts ...Option) *Server {
+	s := &Server{
+		db:        tracing.NewDB(db),
+		tlru:      newTreeCache(DefaultTreeCacheBytes, DefaultTreeCacheEntries),
+		limiter:   newMemoryLimiter(),
+		limits:    DefaultLimits,
+		treeCheck: DefaultTreeCheck,
+	}
+	s.loadTree = func(ctx context.Context, root []byte) (getTreeResp, merkle.Tree, error) {
+		return getTreeLevels(ctx, s.db, root)
+	}
+	s.checkTree = func(ctx context.Context, root []byte) (time.Time, error) {
+		return getLiveTreeInsertedAt(ctx, s.db, root)
+	}
+	s.lookupKey = newKeyCache(apiKeyCacheTTL, func(ctx context.Context, hash []byte) (APIKey, error) {
+		return lookupAPIKey(ctx, s.db, hash)
+	}).get
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.metrics = newMetrics(s)
+	return s
+}
+
+// Returns the mux that serves every route, and the set of
+// routes so that requests can be recorded by route.
+func (s *Server) mux(gitSha string) (*http.ServeMux, map[string]bool) {
+	var (
+		mux    = http.NewServeMux()
+		routes = map[string]bool{}
+	)
+	handle := func(pattern string, h http.HandlerFunc) {
+		routes[pattern] = true
+		mux.HandleFunc(pattern, h)
+	}
+	handle("/api/v1/tree", s.TreeHandler)
+	handle("/api/v1/trees", s.ListTrees)
+	handle("/api/v1/proof", s.GetProof)
+	handle("/api/v1/root", s.GetRoot)
+	handle("/api/v1/roots", s.GetRoot)
+	handle("/api/v1/verify", s.VerifyProof)
+	handle("/api/v1/limits", s.Limits)
+	handle("/api/v1/openapi.json", OpenAPIHandler)
+	handle("/admin/cache", s.requireAdmin(s.CacheHandler))
+	handle("/metrics", s.requireAdmin(s.MetricsHandler().ServeHTTP))
+	handle("/health", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, gitSha)
 	})
+	return mux, routes
+}
+
+func (s *Server) Handler(env, gitSha string) http.Handler {
+	mux, routes := s.mux(gitSha)
 
 	h := http.Handler(mux)
+	h = s.rateLimitHandler(h)
+	h = s.authHandler(h)
+	h = compressHandler(h)
 	h = versionHandler(h, gitSha)
+	h = s.metrics.handler(routes, h)
 	h = hlog.UserAgentHandler("user_agent")(h)
 	h = hlog.RefererHandler("referer")(h)
 	h = hlog.RequestIDHandler("req_id", "Request-Id")(h)
 	h = hlog.URLHandler("path")(h)
 	h = hlog.MethodHandler("method")(h)
 	h = tracingHandler(os.Getenv("DD_ENV"), os.Getenv("DD_SERVICE"), gitSha, h)
-	h = RemoteAddrHandler("ip")(h)
+	h = RemoteAddrHandler("ip", s.proxies)(h)
 	h = hlog.NewHandler(log.Logger)(h) // needs to be last for log values to correctly be passed to context
 
 	if env == "production" {
@@ -73,34 +173,16 @@ func (s *Server) Handler(env, gitSha string) http.Handler {
 	return h
 }
 
-func ipFromRequest(r *http.Request) string {
-	if r.Header.Get("fastly-client-ip") != "" {
-		return r.Header.Get("fastly-client-ip")
-	}
-
-	if r.Header.Get("x-forwarded-for") != "" {
-		group := strings.Split(r.Header.Get("x-forwarded-for"), ", ")
-		if len(group) > 0 {
-			return group[len(group)-1]
-		}
-	}
-
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err == nil {
-		return host
-	}
-
-	return ""
-}
-
-func RemoteAddrHandler(fieldKey string) func(next http.Handler) http.Handler {
+// RemoteAddrHandler logs the client IP, read from the
+// headers of trusted proxies, in fieldKey.
+func RemoteAddrHandler(fieldKey string, tp TrustedProxies) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := ipFromRequest(r)
+			ip := tp.ClientIP(r)
 			if ip != "" {
 				log := zerolog.Ctx(r.Context())
 				log.UpdateContext(func(c zerolog.Context) zerolog.Context {
-					return c.Str("ip", ip)
+					return c.Str(fieldKey, ip)
 				})
 			}
 
@@ -123,24 +205,30 @@ func tracingHandler(env, service, sha string, h http.Handler) http.Handler {
 			return
 		}
 
-		span, ctx := tracing.SpanFromContext(r.Context(), "http.request")
-
-		defer span.Finish()
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.Start(ctx, "http.request",
+			tracing.WithKind(tracing.KindServer),
+			tracing.WithAttributes(
+				tracing.String("http.method", r.Method),
+				tracing.String("http.route", r.URL.Path),
+			),
+		)
+		defer span.End()
 		log := zerolog.Ctx(ctx)
 
-		if env != "" {
+		if id := span.TraceID(); id != "" {
 			log.UpdateContext(func(c zerolog.Context) zerolog.Context {
-				return c.Uint64("dd.trace_id", span.Context().TraceID()).
-					Str("dd.service", service).
-					Str("dd.env", env).
-					Str("dd.version", sha)
+				c = c.Str("trace_id", id)
+				if env != "" && tracing.IsDatadog() {
+					c = c.Str("dd.trace_id", id).
+						Str("dd.service", service).
+						Str("dd.env", env).
+						Str("dd.version", sha)
+				}
+				return c
 			})
 		}
 
-		span.SetTag(ext.ResourceName, r.URL.Path)
-		span.SetTag(ext.SpanType, ext.SpanTypeWeb)
-		span.SetTag(ext.HTTPMethod, r.Method)
-
 		sc := &statusCapture{ResponseWriter: w}
 
 		requestStart := time.Now()
@@ -152,7 +240,10 @@ func tracingHandler(env, service, sha string, h http.Handler) http.Handler {
 			Dur("duration", time.Since(requestStart)).
 			Msg("")
 
-		span.SetTag(ext.HTTPCode, sc.status)
+		span.SetAttributes(tracing.Int("http.status_code", sc.status))
+		if sc.status >= 500 {
+			span.RecordError(errors.New(http.StatusText(sc.status)))
+		}
 	})
 }
 
@@ -180,33 +271,3 @@ func (s *Server) sendJSON(r *http.Request, w http.ResponseWriter, response any)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
-
-func (s *Server) sendJSONError(
-	r *http.Request,
-	w http.ResponseWriter,
-	err error,
-	code int,
-	customMessage string,
-) {
-	w.Header().Set("Content-Type", "application/json")
-	if code == http.StatusNotFound && w.Header().Get("Cache-Control") == "" {
-		w.Header().Set("Cache-Control", "no-cache, max-age=0, must-revalidate")
-	}
-
-	// all headers need to be set before this line
-	w.WriteHeader(code)
-
-	if err != nil {
-		log.Ctx(r.Context()).Err(err).Send()
-	}
-
-	message := http.StatusText(code)
-	if customMessage != "" {
-		message = customMessage
-	}
-
-	json.NewEncoder(w).Encode(map[string]any{
-		"error":   true,
-		"message": message,
-	})
-}
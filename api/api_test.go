@@ -0,0 +1,32 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/contextwtf/lanyard/api/tracing"
+	"github.com/rs/zerolog"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTracingHandlerLogFields(t *testing.T) {
+	tracing.SetTracer(tracing.NewOTel(sdktrace.NewTracerProvider()))
+	defer tracing.SetTracer(nil)
+
+	var (
+		buf bytes.Buffer
+		h   = tracingHandler("production", "lanyard", "abc", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		r   = httptest.NewRequest("GET", "/api/v1/limits", nil)
+	)
+	r = r.WithContext(zerolog.New(&buf).WithContext(r.Context()))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	// DD_ENV is set but the trace ids are OpenTelemetry's
+	logs := buf.String()
+	if !strings.Contains(logs, `"trace_id"`) || strings.Contains(logs, `"dd.`) {
+		t.Errorf("expected trace_id without dd fields, got %s", logs)
+	}
+}
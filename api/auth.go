@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// WithAPIKeys requires an API key with the matching scope
+// for write endpoints. Reads stay public. Without it, keys
+// are optional but still checked when sent, and the key's
+// name is recorded as the owner of the trees it creates
+// either way. Keys are managed with cmd/admin.
+func WithAPIKeys() Option {
+	return func(s *Server) {
+		s.requireKeys = true
+	}
+}
+
+type apiKeyCtxKey struct{}
+
+func withAPIKey(ctx context.Context, k APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyCtxKey{}, k)
+}
+
+func apiKeyFromContext(ctx context.Context) (APIKey, bool) {
+	k, ok := ctx.Value(apiKeyCtxKey{}).(APIKey)
+	return k, ok
+}
+
+// Returns the key sent as a bearer token or in
+// the X-Api-Key header, or an empty string.
+func requestAPIKey(h http.Header) string {
+	if k := h.Get("X-Api-Key"); k != "" {
+		return k
+	}
+	if a := h.Get("Authorization"); strings.HasPrefix(a, "Bearer ") {
+		return strings.TrimPrefix(a, "Bearer ")
+	}
+	return ""
+}
+
+// Looks up key and adds it to the context. An empty
+// key leaves the context unchanged.
+func (s *Server) authenticate(ctx context.Context, key string) (context.Context, error) {
+	if key == "" {
+		return ctx, nil
+	}
+	k, err := s.lookupKey(ctx, hashAPIKey(key))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ctx, &apiError{status: http.StatusUnauthorized, code: codeUnauthorized, msg: "invalid api key"}
+	} else if err != nil {
+		return ctx, internalError(err, "selecting api key")
+	}
+	zerolog.Ctx(ctx).UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.Str("api_key", k.Name)
+	})
+	return withAPIKey(ctx, k), nil
+}
+
+// Returns an error unless the request's key has scope.
+// Requests without a key are allowed when keys aren't required.
+func (s *Server) authorize(ctx context.Context, scope string) error {
+	k, ok := apiKeyFromContext(ctx)
+	switch {
+	case !ok && !s.requireKeys:
+		return nil
+	case !ok:
+		return &apiError{status: http.StatusUnauthorized, code: codeUnauthorized, msg: "missing api key"}
+	case !k.hasScope(scope):
+		return &apiError{
+			status:  http.StatusForbidden,
+			code:    codeForbidden,
+			msg:     "api key is not allowed to " + scope,
+			details: map[string]any{"scope": scope},
+		}
+	}
+	return nil
+}
+
+// Returns the name of the key used for the request,
+// or nil if there wasn't one.
+func owner(ctx context.Context) *string {
+	if k, ok := apiKeyFromContext(ctx); ok {
+		return &k.Name
+	}
+	return nil
+}
+
+// authHandler authenticates requests to /api that carry a key,
+// whether or not keys are required by [WithAPIKeys], so that
+// keys can own trees and read and delete them. A request with
+// an invalid key is rejected, and counted against the rate
+// limit of its IP.
+func (s *Server) authHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			h.ServeHTTP(w, r)
+			return
+		}
+		ctx, err := s.authenticate(r.Context(), requestAPIKey(r.Header))
+		if err != nil {
+			err = s.rateLimitFailedAuth(r.Context(), s.proxies.ClientIP(r), isWrite(r), err)
+			s.sendJSONError(r, w, err)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// The grpc equivalent of authHandler. Keys are read
+// from the authorization or x-api-key metadata.
+func (s *Server) authInterceptor(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	var (
+		md, _ = metadata.FromIncomingContext(ctx)
+		h     = http.Header{}
+	)
+	for _, k := range []string{"x-api-key", "authorization"} {
+		if v := md.Get(k); len(v) > 0 {
+			h.Set(k, v[0])
+		}
+	}
+	kctx, err := s.authenticate(ctx, requestAPIKey(h))
+	if err != nil {
+		err = s.rateLimitFailedAuth(ctx, s.grpcClientIP(ctx), isGRPCWrite(info), err)
+		return nil, grpcError(ctx, err)
+	}
+	return handler(kctx, req)
+}
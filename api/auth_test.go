@@ -0,0 +1,205 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/contextwtf/lanyard/merkle"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/jackc/pgx/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func testKeyServer(opts ...Option) *Server {
+	keys := map[string]APIKey{
+		string(hashAPIKey("writer")): {Name: "writer", Scopes: []string{ScopeTreesWrite}},
+		string(hashAPIKey("reader")): {Name: "reader"},
+	}
+	s := &Server{
+		tlru:       newTreeCache(0, 0),
+		adminToken: "admin",
+		lookupKey: func(ctx context.Context, hash []byte) (APIKey, error) {
+			k, ok := keys[string(hash)]
+			if !ok {
+				return APIKey{}, pgx.ErrNoRows
+			}
+			return k, nil
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func TestCreateTreeRequiresKey(t *testing.T) {
+	s := testKeyServer(WithAPIKeys())
+	cases := []struct {
+		header http.Header
+		status int
+		code   string
+	}{
+		{
+			status: http.StatusUnauthorized,
+			code:   codeUnauthorized,
+		},
+		{
+			header: http.Header{"X-Api-Key": {"wrong"}},
+			status: http.StatusUnauthorized,
+			code:   codeUnauthorized,
+		},
+		{
+			header: http.Header{"Authorization": {"Bearer reader"}},
+			status: http.StatusForbidden,
+			code:   codeForbidden,
+		},
+		{
+			// authorized, so the empty body is rejected
+			header: http.Header{"Authorization": {"Bearer writer"}},
+			status: http.StatusBadRequest,
+			code:   codeInvalidRequest,
+		},
+	}
+	for _, c := range cases {
+		var (
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest("POST", "/api/v1/tree", bytes.NewReader(nil))
+		)
+		for k, v := range c.header {
+			r.Header[k] = v
+		}
+		s.Handler("production", "").ServeHTTP(w, r)
+
+		var resp errorResp
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != c.status || resp.Code != c.code {
+			t.Errorf("%v: expected %d %s, got %d %s", c.header, c.status, c.code, w.Code, resp.Code)
+		}
+	}
+}
+
+func TestReadsArePublic(t *testing.T) {
+	s := testKeyServer(WithAPIKeys())
+	w := httptest.NewRecorder()
+	s.Handler("production", "").ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 without a key, got %d", w.Code)
+	}
+
+	// the admin token isn't mistaken for an api key
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/admin/cache", nil)
+	r.Header.Set("Authorization", "Bearer admin")
+	s.Handler("production", "").ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 for the admin token, got %d", w.Code)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	ctx := context.Background()
+	if err := testKeyServer().authorize(ctx, ScopeTreesWrite); err != nil {
+		t.Errorf("expected writes without a key when keys aren't required, got %v", err)
+	}
+
+	s := testKeyServer(WithAPIKeys())
+	ctx, err := s.authenticate(ctx, "writer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.authorize(ctx, ScopeTreesWrite); err != nil {
+		t.Errorf("expected writer to be authorized, got %v", err)
+	}
+	if o := owner(ctx); o == nil || *o != "writer" {
+		t.Errorf("expected writer to own its trees, got %v", o)
+	}
+}
+
+func TestOptionalKeys(t *testing.T) {
+	var (
+		s     = testKeyServer()
+		h     = s.Handler("production", "")
+		owner = "reader"
+		td    = getTreeResp{
+			UnhashedLeaves: []hexutil.Bytes{
+				common.FromHex("0x0000000000000000000000000000000000000001"),
+				common.FromHex("0x0000000000000000000000000000000000000002"),
+			},
+			Ltd:     []string{"address"},
+			private: true,
+			owner:   &owner,
+		}
+		root  = common.BytesToHash(newCachedTree(td, nil).t.Root())
+		proof = "/api/v1/proof?" + url.Values{
+			"root":    {root.Hex()},
+			"address": {"0x0000000000000000000000000000000000000002"},
+		}.Encode()
+	)
+	s.loadTree = func(ctx context.Context, r []byte) (getTreeResp, merkle.Tree, error) {
+		return td, nil, nil
+	}
+
+	cases := []struct {
+		desc   string
+		method string
+		path   string
+		key    string
+		status int
+		code   string
+	}{
+		{"owner reads private tree", "GET", proof, "reader", http.StatusOK, ""},
+		{"private tree without key", "GET", proof, "", http.StatusForbidden, codePrivateTree},
+		{"private tree with another key", "GET", proof, "writer", http.StatusForbidden, codePrivateTree},
+		{"invalid key", "GET", proof, "wrong", http.StatusUnauthorized, codeUnauthorized},
+		{"delete without key", "DELETE", "/api/v1/tree?root=0x01", "", http.StatusUnauthorized, codeUnauthorized},
+		{"delete with key", "DELETE", "/api/v1/tree", "writer", http.StatusBadRequest, codeMissingRoot},
+	}
+	for _, c := range cases {
+		var (
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest(c.method, c.path, nil)
+		)
+		if c.key != "" {
+			r.Header.Set("X-Api-Key", c.key)
+		}
+		h.ServeHTTP(w, r)
+
+		var resp errorResp
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != c.status || resp.Code != c.code {
+			t.Errorf("%s: expected %d %s, got %d %s", c.desc, c.status, c.code, w.Code, resp.Code)
+		}
+	}
+}
+
+func TestOptionalKeyOwner(t *testing.T) {
+	s := testKeyServer()
+
+	var got *string
+	h := s.authHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = owner(r.Context())
+	}))
+	r := httptest.NewRequest("POST", "/api/v1/tree", nil)
+	r.Header.Set("Authorization", "Bearer writer")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if got == nil || *got != "writer" {
+		t.Errorf("expected writer to own the tree, got %v", got)
+	}
+
+	got = nil
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "writer"))
+	s.authInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+		got = owner(ctx)
+		return nil, nil
+	})
+	if got == nil || *got != "writer" {
+		t.Errorf("expected writer to own the grpc tree, got %v", got)
+	}
+}
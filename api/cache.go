@@ -0,0 +1,178 @@
+package api
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// treeCache is an LRU of cachedTree values bounded by the
+// approximate number of bytes held by the cached trees
+// rather than the number of trees. A handful of very large
+// trees and thousands of small ones cost the same amount
+// of memory.
+type treeCache struct {
+	maxBytes   int64
+	maxEntries int
+
+	mu    sync.Mutex
+	bytes int64
+	ll    *list.List
+	items map[common.Hash]*list.Element
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type cacheEntry struct {
+	root common.Hash
+	ct   cachedTree
+	size int64
+}
+
+// maxBytes bounds the total weight of the cache and maxEntries
+// bounds the number of trees. A value of 0 disables the bound.
+func newTreeCache(maxBytes int64, maxEntries int) *treeCache {
+	return &treeCache{
+		maxBytes:   maxBytes,
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      map[common.Hash]*list.Element{},
+	}
+}
+
+func (c *treeCache) Get(root common.Hash) (cachedTree, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[root]
+	if !ok {
+		c.misses.Add(1)
+		return cachedTree{}, false
+	}
+	c.hits.Add(1)
+	c.ll.MoveToFront(e)
+	return e.Value.(*cacheEntry).ct, true
+}
+
+// Returns the tree without updating its recency
+// or the cache's hit and miss counts.
+func (c *treeCache) Peek(root common.Hash) (cachedTree, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[root]
+	if !ok {
+		return cachedTree{}, false
+	}
+	return e.Value.(*cacheEntry).ct, true
+}
+
+// Adds the tree to the cache, evicting the least recently
+// used trees until it fits. Trees that are larger than the
+// cache itself are not stored.
+func (c *treeCache) Add(root common.Hash, ct cachedTree) {
+	size := ct.size()
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[root]; ok {
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[root] = c.ll.PushFront(&cacheEntry{root: root, ct: ct, size: size})
+	c.bytes += size
+
+	for c.full() {
+		c.removeElement(c.ll.Back())
+		c.evictions.Add(1)
+	}
+}
+
+func (c *treeCache) full() bool {
+	if c.maxBytes > 0 && c.bytes > c.maxBytes {
+		return true
+	}
+	return c.maxEntries > 0 && c.ll.Len() > c.maxEntries
+}
+
+// Removes the tree from the cache.
+// Returns false if the tree wasn't cached.
+func (c *treeCache) Remove(root common.Hash) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[root]
+	if !ok {
+		return false
+	}
+	c.removeElement(e)
+	return true
+}
+
+// Removes every tree from the cache and
+// returns the number of trees removed.
+func (c *treeCache) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.ll.Len()
+	c.ll.Init()
+	c.items = map[common.Hash]*list.Element{}
+	c.bytes = 0
+	return n
+}
+
+func (c *treeCache) removeElement(e *list.Element) {
+	ce := c.ll.Remove(e).(*cacheEntry)
+	delete(c.items, ce.root)
+	c.bytes -= ce.size
+}
+
+type cacheStats struct {
+	Entries    int    `json:"entries"`
+	Bytes      int64  `json:"bytes"`
+	MaxEntries int    `json:"maxEntries"`
+	MaxBytes   int64  `json:"maxBytes"`
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	Evictions  uint64 `json:"evictions"`
+}
+
+func (c *treeCache) Stats() cacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return cacheStats{
+		Entries:    c.ll.Len(),
+		Bytes:      c.bytes,
+		MaxEntries: c.maxEntries,
+		MaxBytes:   c.maxBytes,
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+		Evictions:  c.evictions.Load(),
+	}
+}
+
+type cacheEntryInfo struct {
+	Root      common.Hash `json:"root"`
+	LeafCount int         `json:"leafCount"`
+	Bytes     int64       `json:"bytes"`
+}
+
+// Returns the cached trees from most to least recently used.
+func (c *treeCache) Entries() []cacheEntryInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]cacheEntryInfo, 0, c.ll.Len())
+	for e := c.ll.Front(); e != nil; e = e.Next() {
+		ce := e.Value.(*cacheEntry)
+		entries = append(entries, cacheEntryInfo{
+			Root:      ce.root,
+			LeafCount: len(ce.ct.r.UnhashedLeaves),
+			Bytes:     ce.size,
+		})
+	}
+	return entries
+}
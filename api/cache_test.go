@@ -0,0 +1,114 @@
+package api
+
+import (
+	"math/big"
+	"runtime"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func testCachedTree(leaves ...string) cachedTree {
+	td := getTreeResp{}
+	for _, l := range leaves {
+		td.UnhashedLeaves = append(td.UnhashedLeaves, hexutil.Bytes(l))
+	}
+	return newCachedTree(td, nil)
+}
+
+func TestTreeCacheWeight(t *testing.T) {
+	var (
+		a = testCachedTree("a", "b")
+		b = testCachedTree("c", "d")
+		c = testCachedTree("e", "f")
+	)
+	// room for two trees but not three
+	tc := newTreeCache(a.size()*2, 0)
+	tc.Add(common.Hash{1}, a)
+	tc.Add(common.Hash{2}, b)
+	if _, ok := tc.Get(common.Hash{1}); !ok {
+		t.Fatal("expected tree 1 to be cached")
+	}
+	tc.Add(common.Hash{3}, c)
+
+	if _, ok := tc.Get(common.Hash{2}); ok {
+		t.Error("expected least recently used tree to be evicted")
+	}
+	if _, ok := tc.Get(common.Hash{1}); !ok {
+		t.Error("expected tree 1 to be cached")
+	}
+
+	st := tc.Stats()
+	if st.Entries != 2 || st.Bytes != a.size()*2 {
+		t.Errorf("expected 2 entries of %d bytes, got %d entries of %d bytes", a.size()*2, st.Entries, st.Bytes)
+	}
+	if st.Hits != 2 || st.Misses != 1 || st.Evictions != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestTreeCacheEntries(t *testing.T) {
+	tc := newTreeCache(0, 1)
+	tc.Add(common.Hash{1}, testCachedTree("a", "b"))
+	tc.Add(common.Hash{2}, testCachedTree("c", "d"))
+
+	entries := tc.Entries()
+	if len(entries) != 1 || entries[0].Root != (common.Hash{2}) {
+		t.Errorf("expected only tree 2 to be cached, got %+v", entries)
+	}
+}
+
+func TestTreeCacheOversized(t *testing.T) {
+	ct := testCachedTree("a", "b")
+	tc := newTreeCache(ct.size()-1, 0)
+	tc.Add(common.Hash{1}, ct)
+	if _, ok := tc.Get(common.Hash{1}); ok {
+		t.Error("expected tree larger than the cache not to be cached")
+	}
+}
+
+func TestTreeCachePurge(t *testing.T) {
+	tc := newTreeCache(0, 0)
+	tc.Add(common.Hash{1}, testCachedTree("a", "b"))
+	tc.Add(common.Hash{2}, testCachedTree("c", "d"))
+
+	if !tc.Remove(common.Hash{1}) {
+		t.Error("expected tree 1 to be removed")
+	}
+	if tc.Remove(common.Hash{1}) {
+		t.Error("expected tree 1 to already be removed")
+	}
+	if n := tc.Purge(); n != 1 {
+		t.Errorf("expected 1 tree to be purged, got %d", n)
+	}
+	if st := tc.Stats(); st.Entries != 0 || st.Bytes != 0 {
+		t.Errorf("expected empty cache, got %+v", st)
+	}
+}
+
+func TestCachedTreeSize(t *testing.T) {
+	const n = 10000
+	td := getTreeResp{UnhashedLeaves: make([]hexutil.Bytes, n)}
+	for i := range td.UnhashedLeaves {
+		td.UnhashedLeaves[i] = common.BigToAddress(big.NewInt(int64(i))).Bytes()
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	ct := newCachedTree(td, nil)
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(ct)
+
+	// td's leaves were allocated before the measurement
+	var leaves int64
+	for _, l := range td.UnhashedLeaves {
+		leaves += sliceOverhead + int64(len(l))
+	}
+	used := int64(after.HeapAlloc-before.HeapAlloc) + leaves
+	if size := ct.size(); size < used/2 || size > used*2 {
+		t.Errorf("expected about %d bytes, got %d", used, size)
+	}
+}
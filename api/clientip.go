@@ -0,0 +1,170 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies configures how the client IP is read from
+// requests forwarded by proxies. Headers are only read from
+// requests sent by a trusted proxy, in order of precedence.
+//
+// Forwarded (RFC 7239) and X-Forwarded-For list every hop,
+// and the client is the last address that isn't a trusted
+// proxy. Any other header, such as Fastly-Client-IP, holds
+// the client IP itself.
+type TrustedProxies struct {
+	CIDRs   []netip.Prefix
+	Headers []string
+}
+
+// DefaultProxyHeaders are read from trusted proxies
+// unless other headers are configured.
+var DefaultProxyHeaders = []string{"Forwarded", "X-Forwarded-For"}
+
+// ParseTrustedProxies parses comma separated CIDRs, or single
+// addresses, and header names. Headers default to
+// [DefaultProxyHeaders] when empty.
+func ParseTrustedProxies(cidrs, headers string) (TrustedProxies, error) {
+	var tp TrustedProxies
+	for _, c := range splitList(cidrs) {
+		p, err := netip.ParsePrefix(c)
+		if err != nil {
+			a, aerr := netip.ParseAddr(c)
+			if aerr != nil {
+				return TrustedProxies{}, err
+			}
+			p = netip.PrefixFrom(a, a.BitLen())
+		}
+		tp.CIDRs = append(tp.CIDRs, p.Masked())
+	}
+	tp.Headers = splitList(headers)
+	if len(tp.Headers) == 0 {
+		tp.Headers = DefaultProxyHeaders
+	}
+	return tp, nil
+}
+
+func splitList(s string) []string {
+	var l []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+// WithTrustedProxies reads the client IP, which is logged
+// and used for rate limits, from the headers set by tp.
+// By default no proxy is trusted and the IP of the
+// connection is used.
+func WithTrustedProxies(tp TrustedProxies) Option {
+	return func(s *Server) {
+		s.proxies = tp
+	}
+}
+
+func (tp TrustedProxies) trusted(a netip.Addr) bool {
+	a = a.Unmap()
+	for _, p := range tp.CIDRs {
+		if p.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP of the client that sent r, or an
+// empty string if the connection's address can't be parsed.
+func (tp TrustedProxies) ClientIP(r *http.Request) string {
+	return tp.clientIP(r.RemoteAddr, r.Header)
+}
+
+// Returns the client IP of a connection from
+// remoteAddr that sent the headers h.
+func (tp TrustedProxies) clientIP(remoteAddr string, h http.Header) string {
+	remote := parseHostPort(remoteAddr)
+	if !remote.IsValid() {
+		return ""
+	}
+	if !tp.trusted(remote) {
+		return remote.Unmap().String()
+	}
+
+	for _, name := range tp.Headers {
+		values := h.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		var a netip.Addr
+		switch strings.ToLower(name) {
+		case "forwarded":
+			a = tp.lastUntrusted(forwardedFor(values))
+		case "x-forwarded-for":
+			a = tp.lastUntrusted(splitList(strings.Join(values, ",")))
+		default:
+			a, _ = netip.ParseAddr(strings.TrimSpace(values[0]))
+		}
+		if a.IsValid() {
+			return a.Unmap().String()
+		}
+	}
+	return remote.Unmap().String()
+}
+
+// Returns the last address in hops, which are ordered from
+// the client to the closest proxy, that isn't a trusted proxy.
+// Addresses before it may have been set by the client. Returns
+// an invalid address if a hop can't be parsed, since a proxy
+// that hides its client can't be skipped.
+func (tp TrustedProxies) lastUntrusted(hops []string) netip.Addr {
+	var first netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		a := parseHostPort(hops[i])
+		if !a.IsValid() {
+			return netip.Addr{}
+		}
+		if !tp.trusted(a) {
+			return a
+		}
+		first = a
+	}
+	// every hop is a trusted proxy
+	return first
+}
+
+// Returns the for parameter of each element
+// of the RFC 7239 Forwarded header values.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, elem := range strings.Split(v, ",") {
+			var hop string
+			for _, pair := range strings.Split(elem, ";") {
+				k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(k, "for") {
+					hop = strings.Trim(v, `"`)
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// Parses an address with an optional port, such as
+// 192.0.2.1, 192.0.2.1:80, [2001:db8::1]:80 or 2001:db8::1.
+func parseHostPort(s string) netip.Addr {
+	s = strings.TrimSpace(s)
+	if a, err := netip.ParseAddr(s); err == nil {
+		return a
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	a, _ := netip.ParseAddr(strings.Trim(s, "[]"))
+	return a
+}
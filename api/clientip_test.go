@@ -0,0 +1,173 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tp, err := ParseTrustedProxies("10.0.0.0/8, 2001:db8:ffff::/48, 192.0.2.100", "Fastly-Client-IP, Forwarded, X-Forwarded-For")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		desc   string
+		remote string
+		header http.Header
+		want   string
+	}{
+		{
+			desc:   "direct",
+			remote: "198.51.100.1:1234",
+			want:   "198.51.100.1",
+		},
+		{
+			desc:   "spoofed fastly header from an untrusted client",
+			remote: "198.51.100.1:1234",
+			header: http.Header{"Fastly-Client-Ip": {"203.0.113.9"}},
+			want:   "198.51.100.1",
+		},
+		{
+			desc:   "spoofed x-forwarded-for from an untrusted client",
+			remote: "198.51.100.1:1234",
+			header: http.Header{"X-Forwarded-For": {"203.0.113.9"}},
+			want:   "198.51.100.1",
+		},
+		{
+			desc:   "spoofed forwarded from an untrusted client",
+			remote: "198.51.100.1:1234",
+			header: http.Header{"Forwarded": {"for=203.0.113.9"}},
+			want:   "198.51.100.1",
+		},
+		{
+			desc:   "fastly header takes precedence",
+			remote: "10.0.0.1:1234",
+			header: http.Header{
+				"Fastly-Client-Ip": {"203.0.113.9"},
+				"X-Forwarded-For":  {"198.51.100.1"},
+			},
+			want: "203.0.113.9",
+		},
+		{
+			desc:   "x-forwarded-for through one proxy",
+			remote: "10.0.0.1:1234",
+			header: http.Header{"X-Forwarded-For": {"203.0.113.9"}},
+			want:   "203.0.113.9",
+		},
+		{
+			desc:   "x-forwarded-for prepended by the client",
+			remote: "10.0.0.1:1234",
+			header: http.Header{"X-Forwarded-For": {"1.2.3.4, 203.0.113.9"}},
+			want:   "203.0.113.9",
+		},
+		{
+			desc:   "x-forwarded-for through several trusted proxies",
+			remote: "10.0.0.1:1234",
+			header: http.Header{"X-Forwarded-For": {"1.2.3.4, 203.0.113.9, 10.0.0.2", "192.0.2.100"}},
+			want:   "203.0.113.9",
+		},
+		{
+			desc:   "x-forwarded-for of only trusted proxies",
+			remote: "10.0.0.1:1234",
+			header: http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			want:   "10.0.0.3",
+		},
+		{
+			desc:   "garbage in x-forwarded-for",
+			remote: "10.0.0.1:1234",
+			header: http.Header{"X-Forwarded-For": {"1.2.3.4, not-an-ip"}},
+			want:   "10.0.0.1",
+		},
+		{
+			desc:   "forwarded takes precedence over x-forwarded-for",
+			remote: "10.0.0.1:1234",
+			header: http.Header{
+				"Forwarded":       {`for=1.2.3.4, for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.2;by=10.0.0.1`},
+				"X-Forwarded-For": {"198.51.100.1"},
+			},
+			want: "2001:db8:cafe::17",
+		},
+		{
+			desc:   "obfuscated forwarded falls back to x-forwarded-for",
+			remote: "10.0.0.1:1234",
+			header: http.Header{
+				"Forwarded":       {"for=_hidden"},
+				"X-Forwarded-For": {"198.51.100.1"},
+			},
+			want: "198.51.100.1",
+		},
+		{
+			desc:   "trusted ipv6 proxy",
+			remote: "[2001:db8:ffff::1]:443",
+			header: http.Header{"Forwarded": {"for=198.51.100.1:5555"}},
+			want:   "198.51.100.1",
+		},
+		{
+			desc:   "ipv4 mapped proxy address",
+			remote: "[::ffff:10.0.0.1]:1234",
+			header: http.Header{"X-Forwarded-For": {"203.0.113.9"}},
+			want:   "203.0.113.9",
+		},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = c.remote
+		for k, v := range c.header {
+			r.Header[k] = v
+		}
+		if got := tp.ClientIP(r); got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.desc, c.want, got)
+		}
+	}
+}
+
+func TestClientIPNoTrustedProxies(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "198.51.100.1:1234"
+	r.Header.Set("Fastly-Client-Ip", "203.0.113.9")
+	r.Header.Set("X-Forwarded-For", "203.0.113.9")
+	if got := (TrustedProxies{}).ClientIP(r); got != "198.51.100.1" {
+		t.Errorf("expected the connection's ip, got %q", got)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tp, err := ParseTrustedProxies("10.1.2.3/8,::1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tp.CIDRs) != 2 || tp.CIDRs[0].String() != "10.0.0.0/8" || tp.CIDRs[1].String() != "::1/128" {
+		t.Errorf("unexpected cidrs %v", tp.CIDRs)
+	}
+	if len(tp.Headers) != len(DefaultProxyHeaders) {
+		t.Errorf("expected the default headers, got %v", tp.Headers)
+	}
+	if _, err := ParseTrustedProxies("10.0.0.0/33", ""); err == nil {
+		t.Error("expected an error for an invalid cidr")
+	}
+}
+
+func TestRateLimitSpoofedIP(t *testing.T) {
+	s := testKeyServer(
+		WithRateLimits(RateLimit{}, PerMinute(1, 1)),
+		WithRateLimiter(newMemoryLimiter()),
+	)
+	h := s.Handler("production", "")
+
+	// a client rotating x-forwarded-for shares one budget
+	statuses := []int{}
+	for _, spoofed := range []string{"203.0.113.1", "203.0.113.2"} {
+		var (
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest("GET", "/api/v1/openapi.json", nil)
+		)
+		r.RemoteAddr = "198.51.100.1:1234"
+		r.Header.Set("X-Forwarded-For", spoofed)
+		h.ServeHTTP(w, r)
+		statuses = append(statuses, w.Code)
+	}
+	if statuses[0] != http.StatusOK || statuses[1] != http.StatusTooManyRequests {
+		t.Errorf("expected the second request to be limited, got %v", statuses)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/contextwtf/lanyard/api/migrations"
+	"github.com/contextwtf/migrate"
+	"github.com/jackc/pgx/v4/pgxpool"
+	_ "github.com/jackc/pgx/v4/stdlib"
+)
+
+// Returns a server using the migrated db at TEST_DATABASE_URL,
+// or skips the test if it isn't set. Tests create trees with
+// random leaves so that they don't depend on each other.
+func testDBServer(t *testing.T, opts ...Option) *Server {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL isn't set")
+	}
+	ctx := context.Background()
+
+	mdb, err := sql.Open("pgx", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mdb.Close()
+	if err := migrate.Run(ctx, mdb, migrations.Migrations); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := pgxpool.Connect(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	return New(db, opts...)
+}
+
+// Returns n random leaves.
+func randomLeaves(t *testing.T, n int) [][]byte {
+	t.Helper()
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = make([]byte, 32)
+		if _, err := rand.Read(leaves[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return leaves
+}
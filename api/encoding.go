@@ -0,0 +1,185 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	contentTypeJSON = "application/json"
+	contentTypeCBOR = "application/cbor"
+)
+
+// Returns the content type for the response based on the
+// request's Accept header. JSON is used unless the
+// client prefers CBOR.
+func responseContentType(r *http.Request) string {
+	var (
+		best  = contentTypeJSON
+		bestQ = -1.0
+	)
+	for _, accepted := range parseAccept(r.Header.Get("Accept")) {
+		switch accepted.value {
+		case contentTypeCBOR, contentTypeJSON, "*/*", "application/*":
+		default:
+			continue
+		}
+		if accepted.q > bestQ {
+			best, bestQ = accepted.value, accepted.q
+		}
+	}
+	if best == contentTypeCBOR {
+		return contentTypeCBOR
+	}
+	return contentTypeJSON
+}
+
+// sendEncoded is like sendJSON but encodes the
+// response using the content type negotiated
+// by [responseContentType].
+func (s *Server) sendEncoded(r *http.Request, w http.ResponseWriter, response any) {
+	if responseContentType(r) != contentTypeCBOR {
+		s.sendJSON(r, w, response)
+		return
+	}
+
+	b, err := cbor.Marshal(response)
+	if err != nil {
+		s.sendJSONError(r, w, internalError(err, "encoding response"))
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeCBOR)
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+type acceptValue struct {
+	value string
+	q     float64
+}
+
+// Parses Accept and Accept-Encoding style headers.
+// Values with q=0 are omitted.
+func parseAccept(h string) []acceptValue {
+	var values []acceptValue
+	for _, part := range strings.Split(h, ",") {
+		params := strings.Split(part, ";")
+		v := acceptValue{
+			value: strings.ToLower(strings.TrimSpace(params[0])),
+			q:     1,
+		}
+		if v.value == "" {
+			continue
+		}
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if q, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					v.q = q
+				}
+			}
+		}
+		if v.q > 0 {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Returns "br", "gzip" or "" for the content coding to use
+// for the response based on the Accept-Encoding header.
+// Brotli is preferred when the client weighs both equally.
+func responseEncoding(r *http.Request) string {
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, accepted := range parseAccept(r.Header.Get("Accept-Encoding")) {
+		switch accepted.value {
+		case "br", "gzip":
+		default:
+			continue
+		}
+		if accepted.q > bestQ || (accepted.q == bestQ && accepted.value == "br") {
+			best, bestQ = accepted.value, accepted.q
+		}
+	}
+	return best
+}
+
+// compressHandler compresses responses using brotli or gzip
+// when the client accepts it. The content coding is appended
+// to strong ETags, as each coding is a different representation,
+// and is removed from If-None-Match before h sees it.
+func compressHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		enc := responseEncoding(r)
+		if enc == "" || r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if inm := r.Header.Get("If-None-Match"); inm != "" {
+			r.Header.Set("If-None-Match", strings.ReplaceAll(inm, `-`+enc+`"`, `"`))
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: enc}
+		defer cw.Close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	wroteHeader bool
+	w           io.WriteCloser
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+
+	h := c.Header()
+	if code != http.StatusNoContent && code != http.StatusNotModified && h.Get("Content-Encoding") == "" {
+		switch c.encoding {
+		case "br":
+			c.w = brotli.NewWriter(c.ResponseWriter)
+		case "gzip":
+			c.w = gzip.NewWriter(c.ResponseWriter)
+		}
+		h.Set("Content-Encoding", c.encoding)
+		h.Del("Content-Length")
+	}
+	if tag := h.Get("ETag"); strings.HasPrefix(tag, `"`) {
+		h.Set("ETag", strings.TrimSuffix(tag, `"`)+`-`+c.encoding+`"`)
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.w == nil {
+		return c.ResponseWriter.Write(b)
+	}
+	return c.w.Write(b)
+}
+
+func (c *compressWriter) Close() error {
+	if c.w == nil {
+		return nil
+	}
+	return c.w.Close()
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestResponseContentType(t *testing.T) {
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", contentTypeJSON},
+		{"*/*", contentTypeJSON},
+		{"application/cbor", contentTypeCBOR},
+		{"application/cbor, application/json;q=0.9", contentTypeCBOR},
+		{"application/json, application/cbor;q=0.5", contentTypeJSON},
+		{"application/cbor;q=0", contentTypeJSON},
+		{"text/html", contentTypeJSON},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", c.accept)
+		if got := responseContentType(r); got != c.want {
+			t.Errorf("%q: expected %s got %s", c.accept, c.want, got)
+		}
+	}
+}
+
+func TestResponseEncoding(t *testing.T) {
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip, deflate", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Encoding", c.accept)
+		if got := responseEncoding(r); got != c.want {
+			t.Errorf("%q: expected %q got %q", c.accept, c.want, got)
+		}
+	}
+}
+
+func TestCompressHandler(t *testing.T) {
+	const body = `{"unhashedLeaves": []}`
+	h := compressHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc"`)
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		io.WriteString(w, body)
+	}))
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"": func(r io.Reader) (io.Reader, error) { return r, nil },
+		"gzip": func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+		"br": func(r io.Reader) (io.Reader, error) {
+			return brotli.NewReader(r), nil
+		},
+	}
+	for enc, decode := range decoders {
+		var (
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest(http.MethodGet, "/", nil)
+		)
+		r.Header.Set("Accept-Encoding", enc)
+		h.ServeHTTP(w, r)
+
+		if got := w.Header().Get("Content-Encoding"); got != enc {
+			t.Errorf("%q: expected content encoding %q got %q", enc, enc, got)
+		}
+		dr, err := decode(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(dr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != body {
+			t.Errorf("%q: expected body %s got %s", enc, body, b)
+		}
+
+		tag := w.Header().Get("ETag")
+		if enc != "" && tag != `"abc-`+enc+`"` {
+			t.Errorf("%q: unexpected etag %s", enc, tag)
+		}
+
+		w = httptest.NewRecorder()
+		r.Header.Set("If-None-Match", tag)
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("%q: expected 304 for etag %s got %d", enc, tag, w.Code)
+		}
+		if w.Header().Get("ETag") != tag {
+			t.Errorf("%q: expected etag %s got %s", enc, tag, w.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestSendEncodedCBOR(t *testing.T) {
+	var (
+		w    = httptest.NewRecorder()
+		r    = httptest.NewRequest(http.MethodGet, "/", nil)
+		want = getProofResp{
+			UnhashedLeaf: hexutil.Bytes{1},
+			Proof:        []hexutil.Bytes{{2}, {3}},
+		}
+	)
+	r.Header.Set("Accept", contentTypeCBOR)
+	(&Server{}).sendEncoded(r, w, want)
+
+	if w.Header().Get("Content-Type") != contentTypeCBOR {
+		t.Fatalf("expected cbor content type, got %s", w.Header().Get("Content-Type"))
+	}
+	var got getProofResp
+	if err := cbor.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.UnhashedLeaf.String() != want.UnhashedLeaf.String() || len(got.Proof) != 2 {
+		t.Errorf("expected %+v got %+v", want, got)
+	}
+}
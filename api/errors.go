@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/hlog"
+	"github.com/rs/zerolog/log"
+)
+
+// Error codes are sent with every error response so that
+// clients don't need to match on messages. They must not
+// change once published.
+const (
+	codeInvalidRequest = "INVALID_REQUEST"
+	codeMissingRoot    = "MISSING_ROOT"
+	codeMissingLeaf    = "MISSING_LEAF"
+	codeInvalidLeaf    = "INVALID_LEAF"
+	codeInvalidProof   = "INVALID_PROOF"
+	codeTooFewLeaves   = "TOO_FEW_LEAVES"
+	codeTreeNotFound   = "TREE_NOT_FOUND"
+	codeLeafNotFound   = "LEAF_NOT_FOUND"
+	codeRootNotFound   = "ROOT_NOT_FOUND"
+	codeUnauthorized   = "UNAUTHORIZED"
+	codeForbidden      = "FORBIDDEN"
+	codePrivateTree    = "PRIVATE_TREE"
+	codeRateLimited    = "RATE_LIMITED"
+	codeBodyTooLarge   = "BODY_TOO_LARGE"
+	codeTooManyLeaves  = "TOO_MANY_LEAVES"
+	codeTreeExists     = "TREE_EXISTS"
+	codeInternal       = "INTERNAL"
+)
+
+// An apiError is returned by the logic shared between the
+// http and grpc servers. Its code, message and details are
+// safe to send to clients while err, if set, is only logged.
+type apiError struct {
+	status  int
+	code    string
+	msg     string
+	details map[string]any
+	err     error
+}
+
+func (e *apiError) Error() string {
+	if e.err != nil {
+		return e.msg + ": " + e.err.Error()
+	}
+	return e.msg
+}
+
+func (e *apiError) Unwrap() error {
+	return e.err
+}
+
+func badRequest(code, msg string) *apiError {
+	return &apiError{status: http.StatusBadRequest, code: code, msg: msg}
+}
+
+func notFound(code, msg string) *apiError {
+	return &apiError{status: http.StatusNotFound, code: code, msg: msg}
+}
+
+func conflict(code, msg string) *apiError {
+	return &apiError{status: http.StatusConflict, code: code, msg: msg}
+}
+
+func internalError(err error, msg string) *apiError {
+	return &apiError{status: http.StatusInternalServerError, code: codeInternal, msg: msg, err: err}
+}
+
+func invalidBody(err error) *apiError {
+	return &apiError{status: http.StatusBadRequest, code: codeInvalidRequest, msg: "invalid request body", err: err}
+}
+
+// Returns a copy of e with details that help
+// the client correct its request.
+func (e *apiError) with(details map[string]any) *apiError {
+	c := *e
+	c.details = details
+	return &c
+}
+
+type errorResp struct {
+	Error     bool           `json:"error"`
+	Code      string         `json:"code"`
+	Message   string         `json:"message"`
+	RequestID string         `json:"requestId,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// Sends err using the status, code and message of the
+// apiError it wraps, or a 500 for any other error.
+func (s *Server) sendJSONError(r *http.Request, w http.ResponseWriter, err error) {
+	var ae *apiError
+	if !errors.As(err, &ae) {
+		ae = internalError(err, http.StatusText(http.StatusInternalServerError))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if ae.status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", fmt.Sprint(ae.details["retryAfter"]))
+	}
+	// a 404 may be followed by the tree being created, so
+	// it isn't cached unless the handler has set otherwise
+	if ae.status == http.StatusNotFound && w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "no-cache, max-age=0, must-revalidate")
+	}
+
+	// all headers need to be set before this line
+	w.WriteHeader(ae.status)
+
+	if ae.err != nil {
+		log.Ctx(r.Context()).Err(ae.err).Str("code", ae.code).Send()
+	}
+
+	resp := errorResp{
+		Error:   true,
+		Code:    ae.code,
+		Message: ae.msg,
+		Details: ae.details,
+	}
+	if id, ok := hlog.IDFromRequest(r); ok {
+		resp.RequestID = id.String()
+	}
+	json.NewEncoder(w).Encode(resp)
+}
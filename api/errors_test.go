@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+func TestSendJSONError(t *testing.T) {
+	cases := []struct {
+		err     error
+		status  int
+		code    string
+		msg     string
+		details bool
+	}{
+		{
+			err:    notFound(codeLeafNotFound, "leaf not found in tree"),
+			status: http.StatusNotFound,
+			code:   codeLeafNotFound,
+			msg:    "leaf not found in tree",
+		},
+		{
+			err:     badRequest(codeInvalidLeaf, "invalid leaf").with(map[string]any{"index": 1}),
+			status:  http.StatusBadRequest,
+			code:    codeInvalidLeaf,
+			msg:     "invalid leaf",
+			details: true,
+		},
+		{
+			err:    internalError(errors.New("connection reset"), "selecting tree"),
+			status: http.StatusInternalServerError,
+			code:   codeInternal,
+			msg:    "selecting tree",
+		},
+		{
+			err:    errors.New("connection reset"),
+			status: http.StatusInternalServerError,
+			code:   codeInternal,
+			msg:    "Internal Server Error",
+		},
+	}
+
+	s := &Server{}
+	for _, c := range cases {
+		var (
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest("GET", "/api/v1/proof", nil)
+			h = hlog.RequestIDHandler("req_id", "Request-Id")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				s.sendJSONError(r, w, c.err)
+			}))
+		)
+		h.ServeHTTP(w, r)
+
+		var resp errorResp
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != c.status || resp.Code != c.code || resp.Message != c.msg {
+			t.Errorf("expected %d %s %q, got %d %s %q", c.status, c.code, c.msg, w.Code, resp.Code, resp.Message)
+		}
+		if !resp.Error || resp.RequestID == "" || resp.RequestID != w.Header().Get("Request-Id") {
+			t.Errorf("expected error with request id %q, got %+v", w.Header().Get("Request-Id"), resp)
+		}
+		if (resp.Details != nil) != c.details {
+			t.Errorf("unexpected details %v", resp.Details)
+		}
+		if c.status == http.StatusNotFound {
+			if cc := w.Header().Get("Cache-Control"); cc != "no-cache, max-age=0, must-revalidate" {
+				t.Errorf("expected 404 not to be cached, got %q", cc)
+			}
+		}
+	}
+}
+
+func TestDecodeLeaf(t *testing.T) {
+	cases := []struct {
+		leaf string
+		want string
+		err  bool
+	}{
+		{leaf: "0x0102", want: "0102"},
+		{leaf: "102", want: "0102"},
+		{leaf: "0X01", want: "01"},
+		{leaf: "0xzz", err: true},
+		{leaf: "0x01zz", err: true},
+	}
+	for _, c := range cases {
+		got, err := decodeLeaf(c.leaf)
+		if c.err {
+			if err == nil {
+				t.Errorf("%s: expected error", c.leaf)
+			}
+			continue
+		}
+		if err != nil || fmt.Sprintf("%x", got) != c.want {
+			t.Errorf("%s: expected %s, got %x (%v)", c.leaf, c.want, got, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Returns a strong ETag for a response derived from the kind
+// of response, the tree's root and the request parameters.
+// Trees are content addressed by their root so a response
+// for the same parameters never changes.
+func etag(kind string, root []byte, params ...[]byte) string {
+	parts := append([][]byte{[]byte(kind), root}, params...)
+	return `"` + hexutil.Encode(crypto.Keccak256(parts...)[:16])[2:] + `"`
+}
+
+// Sets the ETag and Last-Modified headers and, if the request's
+// conditional headers show that the client already has the
+// response, writes a 304 and returns true. Any other headers,
+// such as Cache-Control, must be set before calling.
+func notModified(w http.ResponseWriter, r *http.Request, tag string, lastModified time.Time) bool {
+	w.Header().Set("ETag", tag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	// If-Modified-Since is ignored when If-None-Match is present
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatch(inm, tag) {
+			return false
+		}
+	} else {
+		ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || lastModified.IsZero() || lastModified.Truncate(time.Second).After(ims) {
+			return false
+		}
+	}
+
+	w.Header().Del("Content-Type")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// Reports whether the If-None-Match header matches tag
+// using the weak comparison required by RFC 9110.
+func etagMatch(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	var (
+		lastModified = time.Date(2022, 8, 1, 12, 0, 0, 500, time.UTC)
+		tag          = etag("tree", []byte{1})
+	)
+	cases := []struct {
+		desc   string
+		method string
+		header http.Header
+		want   bool
+	}{
+		{"no conditions", http.MethodGet, http.Header{}, false},
+		{"matching etag", http.MethodGet, http.Header{"If-None-Match": {tag}}, true},
+		{"weak matching etag", http.MethodGet, http.Header{"If-None-Match": {`"x", W/` + tag}}, true},
+		{"wildcard", http.MethodGet, http.Header{"If-None-Match": {"*"}}, true},
+		{"other etag", http.MethodGet, http.Header{"If-None-Match": {etag("tree", []byte{2})}}, false},
+		{"post", http.MethodPost, http.Header{"If-None-Match": {tag}}, false},
+		{
+			"not modified since",
+			http.MethodGet,
+			http.Header{"If-Modified-Since": {lastModified.Format(http.TimeFormat)}},
+			true,
+		},
+		{
+			"modified since",
+			http.MethodGet,
+			http.Header{"If-Modified-Since": {lastModified.Add(-time.Hour).Format(http.TimeFormat)}},
+			false,
+		},
+		{
+			"etag takes precedence",
+			http.MethodGet,
+			http.Header{
+				"If-None-Match":     {etag("tree", []byte{2})},
+				"If-Modified-Since": {lastModified.Format(http.TimeFormat)},
+			},
+			false,
+		},
+	}
+
+	for _, c := range cases {
+		var (
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest(c.method, "/api/v1/tree", nil)
+		)
+		r.Header = c.header
+		got := notModified(w, r, tag, lastModified)
+		if got != c.want {
+			t.Errorf("%s: expected %t got %t", c.desc, c.want, got)
+		}
+		if got && w.Code != http.StatusNotModified {
+			t.Errorf("%s: expected status 304 got %d", c.desc, w.Code)
+		}
+		if w.Header().Get("ETag") != tag {
+			t.Errorf("%s: expected etag %s got %s", c.desc, tag, w.Header().Get("ETag"))
+		}
+		if w.Header().Get("Last-Modified") != "Mon, 01 Aug 2022 12:00:00 GMT" {
+			t.Errorf("%s: unexpected last modified %s", c.desc, w.Header().Get("Last-Modified"))
+		}
+	}
+}
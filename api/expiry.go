@@ -0,0 +1,214 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// The longest ttl a tree can be created with.
+const maxTTL = 10 * 365 * 24 * time.Hour
+
+// Returns when a tree created now with a ttl of
+// ttlSeconds expires, or nil if ttlSeconds is 0.
+func expiresAt(ttlSeconds int64, now time.Time) (*time.Time, error) {
+	if ttlSeconds == 0 {
+		return nil, nil
+	}
+	if ttlSeconds < 0 || ttlSeconds > int64(maxTTL/time.Second) {
+		return nil, badRequest(codeInvalidRequest, "invalid ttl").with(map[string]any{
+			"ttlSeconds": ttlSeconds,
+			"max":        int64(maxTTL / time.Second),
+		})
+	}
+	t := now.Add(time.Duration(ttlSeconds) * time.Second).UTC().Truncate(time.Second)
+	return &t, nil
+}
+
+// Returns maxAge, in seconds, reduced so that
+// caches don't hold a response past expiresAt.
+func capMaxAge(maxAge int, expiresAt *time.Time, now time.Time) int {
+	if expiresAt == nil {
+		return maxAge
+	}
+	left := int(expiresAt.Sub(now) / time.Second)
+	if left < 0 {
+		return 0
+	}
+	if left < maxAge {
+		return left
+	}
+	return maxAge
+}
+
+// Deletes the trees, their metadata and their proof hashes.
+func deleteTrees(ctx context.Context, tx pgx.Tx, roots [][]byte) error {
+	const (
+		proofsQ = `
+			-- name: DeleteProofsHashes
+			DELETE FROM proofs_hashes WHERE root = ANY($1)
+		`
+		treesQ = `
+			-- name: DeleteTrees
+			DELETE FROM trees WHERE root = ANY($1)
+		`
+	)
+	if _, err := tx.Exec(ctx, proofsQ, roots); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, treesQ, roots)
+	return err
+}
+
+// DeleteTree handles DELETE /api/v1/tree, which deletes a tree
+// created with the request's API key. The key needs the
+// trees:write scope, whether or not keys are required.
+func (s *Server) DeleteTree(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx  = r.Context()
+		root = r.URL.Query().Get("root")
+	)
+	if root == "" {
+		s.sendJSONError(r, w, badRequest(codeMissingRoot, "missing root"))
+		return
+	}
+	if err := s.deleteTree(ctx, common.FromHex(root)); err != nil {
+		s.sendJSONError(r, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Deletes the tree if it is owned by the request's API key.
+// Trees owned by other keys are reported as not found.
+func (s *Server) deleteTree(ctx context.Context, root []byte) error {
+	k, ok := apiKeyFromContext(ctx)
+	if !ok {
+		return &apiError{status: http.StatusUnauthorized, code: codeUnauthorized, msg: "missing api key"}
+	}
+	if err := s.authorize(ctx, ScopeTreesWrite); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return internalError(err, "creating transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	const q = `
+		-- name: LockOwnedTree
+		SELECT root
+		FROM trees
+		WHERE root = $1 AND owner = $2
+		FOR UPDATE
+	`
+	var owned []byte
+	err = tx.QueryRow(ctx, q, root, k.Name).Scan(&owned)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return notFound(codeTreeNotFound, "tree not found for root")
+	} else if err != nil {
+		return internalError(err, "selecting tree")
+	}
+
+	if err := deleteTrees(ctx, tx, [][]byte{root}); err != nil {
+		return internalError(err, "deleting tree")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return internalError(err, "committing transaction")
+	}
+	s.tlru.Remove(common.BytesToHash(root))
+	return nil
+}
+
+// ErrBatchSize is returned by [Server.CollectExpired]
+// for a batch size less than 1.
+var ErrBatchSize = errors.New("batch size must be at least 1")
+
+// Deletes up to batchSize expired trees and
+// evicts them from the tree cache. Returns
+// the number of trees deleted.
+func (s *Server) CollectExpired(ctx context.Context, batchSize int) (int, error) {
+	if batchSize < 1 {
+		return 0, ErrBatchSize
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// skip locked rows so that several servers
+	// can collect at the same time
+	const q = `
+		-- name: ListExpiredTrees
+		SELECT root
+		FROM trees
+		WHERE expires_at <= now()
+		ORDER BY expires_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+	var (
+		roots [][]byte
+		root  []byte
+	)
+	_, err = tx.QueryFunc(ctx, q, []any{batchSize}, []any{&root}, func(pgx.QueryFuncRow) error {
+		roots = append(roots, root)
+		return nil
+	})
+	if err != nil || len(roots) == 0 {
+		return 0, err
+	}
+
+	if err := deleteTrees(ctx, tx, roots); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	for _, r := range roots {
+		s.tlru.Remove(common.BytesToHash(r))
+	}
+	return len(roots), nil
+}
+
+// RunGC collects expired trees every interval, in batches
+// of batchSize, until ctx is canceled. It returns at once
+// for a batch size less than 1.
+func (s *Server) RunGC(ctx context.Context, interval time.Duration, batchSize int) {
+	if batchSize < 1 {
+		log.Ctx(ctx).Error().Err(ErrBatchSize).Int("batch_size", batchSize).Msg("not collecting expired trees")
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		var total int
+		for {
+			n, err := s.CollectExpired(ctx, batchSize)
+			if err != nil {
+				log.Ctx(ctx).Err(err).Msg("collecting expired trees")
+				break
+			}
+			total += n
+			if n < batchSize {
+				break
+			}
+		}
+		if total > 0 {
+			log.Ctx(ctx).Info().Int("deleted", total).Msg("collected expired trees")
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/contextwtf/lanyard/merkle"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestExpiresAt(t *testing.T) {
+	var (
+		now  = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+		hour = now.Add(time.Hour)
+	)
+	cases := []struct {
+		ttl  int64
+		want *time.Time
+		err  bool
+	}{
+		{ttl: 0},
+		{ttl: 3600, want: &hour},
+		{ttl: -1, err: true},
+		{ttl: int64(maxTTL/time.Second) + 1, err: true},
+	}
+
+	for _, c := range cases {
+		got, err := expiresAt(c.ttl, now)
+		if (err != nil) != c.err {
+			t.Errorf("%d: expected error %t, got %v", c.ttl, c.err, err)
+			continue
+		}
+		if (got == nil) != (c.want == nil) || (got != nil && !got.Equal(*c.want)) {
+			t.Errorf("%d: expected %v, got %v", c.ttl, c.want, got)
+		}
+	}
+}
+
+func TestCapMaxAge(t *testing.T) {
+	var (
+		now   = time.Now()
+		soon  = now.Add(30 * time.Second)
+		later = now.Add(time.Hour)
+		past  = now.Add(-time.Second)
+	)
+	cases := []struct {
+		exp  *time.Time
+		want int
+	}{
+		{nil, 60},
+		{&soon, 30},
+		{&later, 60},
+		{&past, 0},
+	}
+	for _, c := range cases {
+		if got := capMaxAge(60, c.exp, now); got != c.want {
+			t.Errorf("%v: expected %d, got %d", c.exp, c.want, got)
+		}
+	}
+}
+
+func TestCachedTreeExpired(t *testing.T) {
+	var (
+		exp = time.Now().Add(-time.Second)
+		td  = getTreeResp{
+			UnhashedLeaves: []hexutil.Bytes{{0x01}, {0x02}},
+			ExpiresAt:      &exp,
+		}
+		ct   = newCachedTree(td, nil)
+		root = common.BytesToHash(ct.t.Root())
+		s    = &Server{
+			tlru: newTreeCache(0, 0),
+			loadTree: func(ctx context.Context, r []byte) (getTreeResp, merkle.Tree, error) {
+				return getTreeResp{}, nil, pgx.ErrNoRows
+			},
+		}
+	)
+	s.tlru.Add(root, ct)
+
+	_, err := s.getCachedTree(context.Background(), root)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("expected ErrNoRows, got %v", err)
+	}
+	if _, ok := s.tlru.Peek(root); ok {
+		t.Error("expected the expired tree to be evicted")
+	}
+}
+
+func TestDeleteTreeRequiresKey(t *testing.T) {
+	s := testKeyServer(WithAPIKeys())
+	cases := []struct {
+		key    string
+		query  string
+		status int
+		code   string
+	}{
+		{query: "?root=0x01", status: http.StatusUnauthorized, code: codeUnauthorized},
+		{key: "reader", query: "?root=0x01", status: http.StatusForbidden, code: codeForbidden},
+		{key: "writer", status: http.StatusBadRequest, code: codeMissingRoot},
+	}
+	for _, c := range cases {
+		var (
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest("DELETE", "/api/v1/tree"+c.query, nil)
+		)
+		if c.key != "" {
+			r.Header.Set("X-Api-Key", c.key)
+		}
+		s.Handler("production", "").ServeHTTP(w, r)
+
+		var resp errorResp
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != c.status || resp.Code != c.code {
+			t.Errorf("%q: expected %d %s, got %d %s", c.key, c.status, c.code, w.Code, resp.Code)
+		}
+	}
+}
+
+func TestCollectExpiredBatchSize(t *testing.T) {
+	s := &Server{}
+	for _, n := range []int{0, -1} {
+		if _, err := s.CollectExpired(context.Background(), n); !errors.Is(err, ErrBatchSize) {
+			t.Errorf("%d: expected ErrBatchSize, got %v", n, err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.RunGC(context.Background(), time.Millisecond, 0)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected RunGC to return for a batch size of 0")
+	}
+}
@@ -0,0 +1,330 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/contextwtf/lanyard/api/lanyardpb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Returns a grpc server that implements the Lanyard service
+// defined in lanyardpb using the same logic and tree cache
+// as the http handlers. The caller is responsible for
+// serving and stopping it.
+func (s *Server) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	if s.limits.MaxBodyBytes > 0 {
+		// prepended so that the caller's options take precedence
+		opts = append([]grpc.ServerOption{grpc.MaxRecvMsgSize(int(s.limits.MaxBodyBytes))}, opts...)
+	}
+	opts = append(opts, grpc.ChainUnaryInterceptor(s.logInterceptor, s.authInterceptor, s.rateLimitInterceptor))
+	gs := grpc.NewServer(opts...)
+	lanyardpb.RegisterLanyardServer(gs, &grpcServer{s: s})
+	return gs
+}
+
+// logInterceptor adds a logger to the context of each
+// request, which later interceptors and handlers log to,
+// and logs every request like the http handler does.
+func (s *Server) logInterceptor(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	l := log.Logger.With().
+		Str("grpc_method", info.FullMethod).
+		Str("ip", s.grpcClientIP(ctx)).
+		Logger()
+	ctx = l.WithContext(ctx)
+
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	log.Ctx(ctx).Info().
+		Str("grpc_code", status.Code(err).String()).
+		Dur("duration", time.Since(start)).
+		Msg("")
+	return resp, err
+}
+
+type grpcServer struct {
+	lanyardpb.UnimplementedLanyardServer
+	s *Server
+}
+
+// Converts errors returned by the shared server logic
+// to a grpc status. Only the message of an apiError is
+// sent to the client, and its wrapped error is logged
+// like sendJSONError does.
+func grpcError(ctx context.Context, err error) error {
+	var ae *apiError
+	if !errors.As(err, &ae) {
+		log.Ctx(ctx).Err(err).Str("code", codeInternal).Send()
+		return status.Error(codes.Internal, "internal server error")
+	}
+	if ae.err != nil {
+		log.Ctx(ctx).Err(ae.err).Str("code", ae.code).Send()
+	}
+	switch ae.status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return status.Error(codes.InvalidArgument, ae.msg)
+	case http.StatusNotFound:
+		return status.Error(codes.NotFound, ae.msg)
+	case http.StatusUnauthorized:
+		return status.Error(codes.Unauthenticated, ae.msg)
+	case http.StatusForbidden:
+		return status.Error(codes.PermissionDenied, ae.msg)
+	case http.StatusConflict:
+		return status.Error(codes.AlreadyExists, ae.msg)
+	case http.StatusTooManyRequests:
+		return status.Error(codes.ResourceExhausted, ae.msg)
+	default:
+		return status.Error(codes.Internal, ae.msg)
+	}
+}
+
+// Returns the read token sent in the x-read-token metadata, if any.
+func grpcReadToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("x-read-token"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// The grpc equivalent of parseVisibility. Values outside
+// the enum, which newer clients could send, are rejected
+// rather than creating a public tree.
+func visibilityFromProto(v lanyardpb.Visibility) (bool, error) {
+	switch v {
+	case lanyardpb.Visibility_VISIBILITY_UNSPECIFIED, lanyardpb.Visibility_VISIBILITY_PUBLIC:
+		return false, nil
+	case lanyardpb.Visibility_VISIBILITY_PRIVATE:
+		return true, nil
+	}
+	return false, badRequest(codeInvalidRequest, "invalid visibility").with(map[string]any{
+		"visibility": int32(v),
+	})
+}
+
+func (g *grpcServer) CreateTree(ctx context.Context, req *lanyardpb.CreateTreeRequest) (*lanyardpb.CreateTreeResponse, error) {
+	if err := g.s.authorize(ctx, ScopeTreesWrite); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	var md *treeMetadata
+	if req.Metadata != nil {
+		md = metadataFromProto(req.Metadata)
+		if err := md.validate(); err != nil {
+			return nil, grpcError(ctx, err)
+		}
+	}
+	private, err := visibilityFromProto(req.Visibility)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	exp, err := expiresAt(req.TtlSeconds, time.Now())
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	ct, err := g.s.createTree(ctx, treeParams{
+		leaves:    req.UnhashedLeaves,
+		ltd:       req.LeafTypeDescriptor,
+		packed:    req.PackedEncoding,
+		private:   private,
+		metadata:  md,
+		expiresAt: exp,
+	})
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return &lanyardpb.CreateTreeResponse{
+		MerkleRoot: ct.root,
+		ReadToken:  ct.readToken,
+		ExpiresAt:  timestampProto(ct.expiresAt),
+	}, nil
+}
+
+func (g *grpcServer) DeleteTree(ctx context.Context, req *lanyardpb.DeleteTreeRequest) (*lanyardpb.DeleteTreeResponse, error) {
+	if len(req.Root) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "missing root")
+	}
+	if err := g.s.deleteTree(ctx, req.Root); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return &lanyardpb.DeleteTreeResponse{}, nil
+}
+
+func (g *grpcServer) GetTree(ctx context.Context, req *lanyardpb.GetTreeRequest) (*lanyardpb.GetTreeResponse, error) {
+	if len(req.Root) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "missing root")
+	}
+	tr, err := getTree(ctx, g.s.db, req.Root)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "tree not found for root")
+	} else if err != nil {
+		return nil, grpcError(ctx, internalError(err, "selecting tree"))
+	}
+	if !g.s.canRead(ctx, tr, grpcReadToken(ctx)) {
+		return nil, grpcError(ctx, errPrivateTree)
+	}
+
+	resp := &lanyardpb.GetTreeResponse{
+		LeafCount:          int64(len(tr.UnhashedLeaves)),
+		LeafTypeDescriptor: tr.Ltd,
+		PackedEncoding:     tr.Packed,
+		Metadata:           metadataToProto(tr.Metadata),
+		ExpiresAt:          timestampProto(tr.ExpiresAt),
+	}
+	for _, l := range tr.UnhashedLeaves {
+		resp.UnhashedLeaves = append(resp.UnhashedLeaves, l)
+	}
+	return resp, nil
+}
+
+func (g *grpcServer) GetProof(ctx context.Context, req *lanyardpb.GetProofRequest) (*lanyardpb.GetProofResponse, error) {
+	if len(req.Root) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "missing root")
+	}
+	root := common.BytesToHash(req.Root)
+	ct, idx, err := g.s.findLeaf(ctx, root, req.UnhashedLeaf, req.Address, grpcReadToken(ctx))
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return &lanyardpb.GetProofResponse{
+		UnhashedLeaf: ct.r.UnhashedLeaves[idx],
+		Proof:        g.s.proof(ctx, root, &ct, idx),
+	}, nil
+}
+
+func (g *grpcServer) GetProofs(ctx context.Context, req *lanyardpb.GetProofsRequest) (*lanyardpb.GetProofsResponse, error) {
+	if len(req.Root) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "missing root")
+	}
+	if len(req.UnhashedLeaves) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "missing leaves")
+	}
+	root := common.BytesToHash(req.Root)
+	ct, err := g.s.getCachedTree(ctx, root)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "tree not found")
+	} else if err != nil {
+		return nil, grpcError(ctx, internalError(err, "selecting proof"))
+	}
+
+	resp := &lanyardpb.GetProofsResponse{}
+	for _, leaf := range req.UnhashedLeaves {
+		idx := ct.index(leaf, nil)
+		if idx == -1 {
+			continue
+		}
+		resp.Proofs = append(resp.Proofs, &lanyardpb.GetProofResponse{
+			UnhashedLeaf: ct.r.UnhashedLeaves[idx],
+			Proof:        g.s.proof(ctx, root, &ct, idx),
+		})
+	}
+	return resp, nil
+}
+
+func (g *grpcServer) GetRoots(ctx context.Context, req *lanyardpb.GetRootsRequest) (*lanyardpb.GetRootsResponse, error) {
+	if len(req.Proof) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "missing proof")
+	}
+	roots, err := g.s.lookupRoots(ctx, req.Proof)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	resp := &lanyardpb.GetRootsResponse{}
+	for _, r := range roots {
+		resp.Roots = append(resp.Roots, r)
+	}
+	return resp, nil
+}
+
+func (g *grpcServer) ListTrees(ctx context.Context, req *lanyardpb.ListTreesRequest) (*lanyardpb.ListTreesResponse, error) {
+	f := treeFilter{
+		tags:     req.Tags,
+		contract: req.ContractAddress,
+		chainID:  req.ChainId,
+		limit:    int(req.Limit),
+		offset:   int(req.Offset),
+	}.withLimit()
+	if f.offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid offset")
+	}
+	if len(f.contract) == 0 {
+		f.contract = nil
+	}
+
+	trees, err := g.s.listTrees(ctx, f)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	resp := &lanyardpb.ListTreesResponse{}
+	for i := range trees {
+		resp.Trees = append(resp.Trees, &lanyardpb.TreeSummary{
+			MerkleRoot: trees[i].MerkleRoot,
+			Metadata:   metadataToProto(&trees[i].Metadata),
+			InsertedAt: timestamppb.New(trees[i].InsertedAt),
+		})
+	}
+	return resp, nil
+}
+
+func metadataFromProto(m *lanyardpb.TreeMetadata) *treeMetadata {
+	md := &treeMetadata{
+		Name:            m.Name,
+		Description:     m.Description,
+		Tags:            m.Tags,
+		ChainID:         m.ChainId,
+		ContractAddress: m.ContractAddress,
+	}
+	if len(md.ContractAddress) == 0 {
+		md.ContractAddress = nil
+	}
+	if m.ClaimStart != nil {
+		t := m.ClaimStart.AsTime()
+		md.ClaimStart = &t
+	}
+	if m.ClaimEnd != nil {
+		t := m.ClaimEnd.AsTime()
+		md.ClaimEnd = &t
+	}
+	return md
+}
+
+func metadataToProto(md *treeMetadata) *lanyardpb.TreeMetadata {
+	if md == nil {
+		return nil
+	}
+	m := &lanyardpb.TreeMetadata{
+		Name:            md.Name,
+		Description:     md.Description,
+		Tags:            md.Tags,
+		ChainId:         md.ChainID,
+		ContractAddress: md.ContractAddress,
+	}
+	if md.ClaimStart != nil {
+		m.ClaimStart = timestamppb.New(*md.ClaimStart)
+	}
+	if md.ClaimEnd != nil {
+		m.ClaimEnd = timestamppb.New(*md.ClaimEnd)
+	}
+	return m
+}
+
+// Returns nil for a nil time.
+func timestampProto(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
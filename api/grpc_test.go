@@ -0,0 +1,149 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/contextwtf/lanyard/api/lanyardpb"
+	"github.com/contextwtf/lanyard/merkle"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func testGRPCClient(t *testing.T, s *Server) lanyardpb.LanyardClient {
+	t.Helper()
+	var (
+		lis = bufconn.Listen(1 << 20)
+		gs  = s.GRPCServer()
+	)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return lanyardpb.NewLanyardClient(conn)
+}
+
+func TestGRPCGetProof(t *testing.T) {
+	var (
+		leaves = [][]byte{{1}, {2}, {3}}
+		tree   = merkle.New(leaves)
+		root   = tree.Root()
+		s      = &Server{
+			tlru: newTreeCache(0, 0),
+			loadTree: func(ctx context.Context, r []byte) (getTreeResp, merkle.Tree, error) {
+				if !bytes.Equal(r, root) {
+					return getTreeResp{}, nil, pgx.ErrNoRows
+				}
+				td := getTreeResp{}
+				for _, l := range leaves {
+					td.UnhashedLeaves = append(td.UnhashedLeaves, hexutil.Bytes(l))
+				}
+				return td, nil, nil
+			},
+		}
+		c   = testGRPCClient(t, s)
+		ctx = context.Background()
+	)
+
+	resp, err := c.GetProof(ctx, &lanyardpb.GetProofRequest{Root: root, UnhashedLeaf: leaves[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !merkle.Valid(root, resp.Proof, leaves[1]) {
+		t.Error("expected proof to be valid")
+	}
+
+	proofs, err := c.GetProofs(ctx, &lanyardpb.GetProofsRequest{
+		Root:           root,
+		UnhashedLeaves: [][]byte{leaves[0], {4}, leaves[2]},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proofs.Proofs) != 2 {
+		t.Fatalf("expected 2 proofs, got %d", len(proofs.Proofs))
+	}
+	for _, p := range proofs.Proofs {
+		if !merkle.Valid(root, p.Proof, p.UnhashedLeaf) {
+			t.Errorf("expected proof for %x to be valid", p.UnhashedLeaf)
+		}
+	}
+
+	cases := []struct {
+		req  *lanyardpb.GetProofRequest
+		code codes.Code
+	}{
+		{&lanyardpb.GetProofRequest{}, codes.InvalidArgument},
+		{&lanyardpb.GetProofRequest{Root: root}, codes.InvalidArgument},
+		{&lanyardpb.GetProofRequest{Root: root, UnhashedLeaf: []byte{4}}, codes.NotFound},
+		{&lanyardpb.GetProofRequest{Root: common.Hash{1}.Bytes(), UnhashedLeaf: leaves[0]}, codes.NotFound},
+	}
+	for _, tc := range cases {
+		_, err := c.GetProof(ctx, tc.req)
+		if got := status.Code(err); got != tc.code {
+			t.Errorf("expected %s, got %s (%v)", tc.code, got, err)
+		}
+	}
+}
+
+func TestGRPCLogsInternalErrors(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&buf)
+
+	s := &Server{
+		tlru: newTreeCache(0, 0),
+		loadTree: func(ctx context.Context, r []byte) (getTreeResp, merkle.Tree, error) {
+			return getTreeResp{}, nil, errors.New("db down")
+		},
+	}
+	c := testGRPCClient(t, s)
+	_, err := c.GetProofs(context.Background(), &lanyardpb.GetProofsRequest{
+		Root:           []byte{1},
+		UnhashedLeaves: [][]byte{{1}},
+	})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("expected %v, got %v", codes.Internal, err)
+	}
+	if status.Convert(err).Message() != "selecting proof" {
+		t.Errorf("expected the error not to be sent, got %q", status.Convert(err).Message())
+	}
+	logs := buf.String()
+	for _, want := range []string{`"error":"db down"`, `"grpc_method":"/lanyard.v1.Lanyard/GetProofs"`, `"grpc_code":"Internal"`} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("expected %s to be logged, got %s", want, logs)
+		}
+	}
+}
+
+func TestGRPCCreateTreeInvalidVisibility(t *testing.T) {
+	c := testGRPCClient(t, &Server{tlru: newTreeCache(0, 0)})
+	_, err := c.CreateTree(context.Background(), &lanyardpb.CreateTreeRequest{
+		UnhashedLeaves: [][]byte{{1}, {2}},
+		Visibility:     lanyardpb.Visibility(7),
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected %v, got %v", codes.InvalidArgument, err)
+	}
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/contextwtf/lanyard/api/tracing"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Scopes limit what an API key may write.
+// Reads don't require a key.
+const (
+	ScopeTreesWrite = "trees:write"
+)
+
+var Scopes = []string{ScopeTreesWrite}
+
+// An APIKey identifies the owner of the trees created with it.
+// Only a hash of the key itself is stored.
+type APIKey struct {
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	InsertedAt time.Time  `json:"insertedAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+func (k APIKey) hasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hashAPIKey(key string) []byte {
+	h := sha256.Sum256([]byte(key))
+	return h[:]
+}
+
+// Creates a key for name with the given scopes and
+// returns it. The key can't be recovered later.
+func CreateAPIKey(ctx context.Context, db *pgxpool.Pool, name string, scopes []string) (string, error) {
+	if name == "" {
+		return "", errors.New("missing name")
+	}
+	for _, sc := range scopes {
+		if !validScope(sc) {
+			return "", fmt.Errorf("unknown scope %q", sc)
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	key := "lanyard_" + base64.RawURLEncoding.EncodeToString(b)
+
+	const q = `
+		-- name: CreateAPIKey
+		INSERT INTO api_keys (name, key_hash, scopes)
+		VALUES ($1, $2, $3)
+	`
+	if _, err := db.Exec(ctx, q, name, hashAPIKey(key), scopes); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func validScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns every key, including revoked keys,
+// in the order they were created.
+func ListAPIKeys(ctx context.Context, db *pgxpool.Pool) ([]APIKey, error) {
+	const q = `
+		-- name: ListAPIKeys
+		SELECT name, scopes, inserted_at, revoked_at
+		FROM api_keys
+		ORDER BY inserted_at
+	`
+	var (
+		keys []APIKey
+		k    APIKey
+	)
+	_, err := db.QueryFunc(ctx, q, nil, []any{&k.Name, &k.Scopes, &k.InsertedAt, &k.RevokedAt}, func(pgx.QueryFuncRow) error {
+		keys = append(keys, k)
+		return nil
+	})
+	return keys, err
+}
+
+// Revokes the key for name. Trees created with the
+// key keep it as their owner.
+func RevokeAPIKey(ctx context.Context, db *pgxpool.Pool, name string) error {
+	const q = `
+		-- name: RevokeAPIKey
+		UPDATE api_keys
+		SET revoked_at = now()
+		WHERE name = $1 AND revoked_at IS NULL
+	`
+	tag, err := db.Exec(ctx, q, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("no active key named %q", name)
+	}
+	return nil
+}
+
+// Returns the unrevoked key with the given hash,
+// or pgx.ErrNoRows if there isn't one.
+func lookupAPIKey(ctx context.Context, db *tracing.DB, hash []byte) (APIKey, error) {
+	const q = `
+		-- name: LookupAPIKey
+		SELECT name, scopes, inserted_at
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL
+	`
+	var k APIKey
+	err := db.QueryRow(ctx, q, hash).Scan(&k.Name, &k.Scopes, &k.InsertedAt)
+	return k, err
+}
+
+// How long a key found by lookupAPIKey is reused, which
+// is also how long a revoked key can still be used.
+const apiKeyCacheTTL = time.Minute
+
+// keyCache holds the keys found by lookup for ttl so that
+// requests sending a key, such as proof reads that are served
+// from the tree cache, don't each read the db. Keys that
+// aren't found aren't cached, since any string can be sent.
+type keyCache struct {
+	ttl    time.Duration
+	now    func() time.Time
+	lookup func(ctx context.Context, hash []byte) (APIKey, error)
+
+	mu    sync.Mutex
+	keys  map[string]cachedKey
+	swept time.Time
+}
+
+type cachedKey struct {
+	key APIKey
+	at  time.Time
+}
+
+func newKeyCache(ttl time.Duration, lookup func(ctx context.Context, hash []byte) (APIKey, error)) *keyCache {
+	return &keyCache{
+		ttl:    ttl,
+		now:    time.Now,
+		lookup: lookup,
+		keys:   map[string]cachedKey{},
+	}
+}
+
+func (c *keyCache) get(ctx context.Context, hash []byte) (APIKey, error) {
+	now := c.now()
+	c.mu.Lock()
+	ck, ok := c.keys[string(hash)]
+	c.mu.Unlock()
+	if ok && now.Sub(ck.at) < c.ttl {
+		return ck.key, nil
+	}
+
+	k, err := c.lookup(ctx, hash)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.swept) > c.ttl {
+		for h, ck := range c.keys {
+			if now.Sub(ck.at) >= c.ttl {
+				delete(c.keys, h)
+			}
+		}
+		c.swept = now
+	}
+	if err != nil {
+		delete(c.keys, string(hash))
+		return k, err
+	}
+	c.keys[string(hash)] = cachedKey{key: k, at: now}
+	return k, nil
+}
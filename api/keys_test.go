@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func TestKeyCache(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		now     = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+		lookups int
+		revoked bool
+		c       = newKeyCache(time.Minute, func(ctx context.Context, hash []byte) (APIKey, error) {
+			lookups++
+			if revoked || string(hash) != string(hashAPIKey("writer")) {
+				return APIKey{}, pgx.ErrNoRows
+			}
+			return APIKey{Name: "writer"}, nil
+		})
+	)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if k, err := c.get(ctx, hashAPIKey("writer")); err != nil || k.Name != "writer" {
+			t.Fatalf("expected writer, got %v, %v", k, err)
+		}
+		if _, err := c.get(ctx, hashAPIKey("guess")); !errors.Is(err, pgx.ErrNoRows) {
+			t.Fatalf("expected ErrNoRows, got %v", err)
+		}
+	}
+	// the valid key is looked up once, invalid keys every time
+	if lookups != 4 {
+		t.Errorf("expected 4 lookups, got %d", lookups)
+	}
+
+	revoked = true
+	now = now.Add(time.Minute)
+	if _, err := c.get(ctx, hashAPIKey("writer")); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("expected the revoked key to be rejected after the ttl, got %v", err)
+	}
+	if len(c.keys) != 0 {
+		t.Errorf("expected the revoked key to be dropped, got %d keys", len(c.keys))
+	}
+}
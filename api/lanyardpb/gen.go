@@ -0,0 +1,5 @@
+// Package lanyardpb contains the protobuf definitions and
+// generated gRPC server and client for the Lanyard API.
+package lanyardpb
+
+//go:generate buf generate
@@ -0,0 +1,359 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: lanyard.proto
+
+package lanyardpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Lanyard_CreateTree_FullMethodName = "/lanyard.v1.Lanyard/CreateTree"
+	Lanyard_GetTree_FullMethodName    = "/lanyard.v1.Lanyard/GetTree"
+	Lanyard_ListTrees_FullMethodName  = "/lanyard.v1.Lanyard/ListTrees"
+	Lanyard_GetProof_FullMethodName   = "/lanyard.v1.Lanyard/GetProof"
+	Lanyard_GetProofs_FullMethodName  = "/lanyard.v1.Lanyard/GetProofs"
+	Lanyard_GetRoots_FullMethodName   = "/lanyard.v1.Lanyard/GetRoots"
+	Lanyard_DeleteTree_FullMethodName = "/lanyard.v1.Lanyard/DeleteTree"
+)
+
+// LanyardClient is the client API for Lanyard service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type LanyardClient interface {
+	// Creates a tree from unhashed leaves and returns its root.
+	// Creating a tree that already exists returns the same root.
+	CreateTree(ctx context.Context, in *CreateTreeRequest, opts ...grpc.CallOption) (*CreateTreeResponse, error)
+	// Returns the leaves of a published tree. Private trees
+	// require their read token or their owner's API key.
+	GetTree(ctx context.Context, in *GetTreeRequest, opts ...grpc.CallOption) (*GetTreeResponse, error)
+	// Searches the metadata of public trees.
+	ListTrees(ctx context.Context, in *ListTreesRequest, opts ...grpc.CallOption) (*ListTreesResponse, error)
+	// Returns the proof for a leaf, or for the first
+	// leaf containing an address, in a published tree.
+	// Searching a private tree by address requires
+	// its read token or its owner's API key.
+	GetProof(ctx context.Context, in *GetProofRequest, opts ...grpc.CallOption) (*GetProofResponse, error)
+	// Returns the proofs for many leaves in the same tree.
+	// Leaves that aren't in the tree are omitted.
+	GetProofs(ctx context.Context, in *GetProofsRequest, opts ...grpc.CallOption) (*GetProofsResponse, error)
+	// Returns the roots of the published trees containing a proof.
+	// Private trees are never returned.
+	GetRoots(ctx context.Context, in *GetRootsRequest, opts ...grpc.CallOption) (*GetRootsResponse, error)
+	// Deletes a tree created with the request's API key.
+	DeleteTree(ctx context.Context, in *DeleteTreeRequest, opts ...grpc.CallOption) (*DeleteTreeResponse, error)
+}
+
+type lanyardClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLanyardClient(cc grpc.ClientConnInterface) LanyardClient {
+	return &lanyardClient{cc}
+}
+
+func (c *lanyardClient) CreateTree(ctx context.Context, in *CreateTreeRequest, opts ...grpc.CallOption) (*CreateTreeResponse, error) {
+	out := new(CreateTreeResponse)
+	err := c.cc.Invoke(ctx, Lanyard_CreateTree_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lanyardClient) GetTree(ctx context.Context, in *GetTreeRequest, opts ...grpc.CallOption) (*GetTreeResponse, error) {
+	out := new(GetTreeResponse)
+	err := c.cc.Invoke(ctx, Lanyard_GetTree_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lanyardClient) ListTrees(ctx context.Context, in *ListTreesRequest, opts ...grpc.CallOption) (*ListTreesResponse, error) {
+	out := new(ListTreesResponse)
+	err := c.cc.Invoke(ctx, Lanyard_ListTrees_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lanyardClient) GetProof(ctx context.Context, in *GetProofRequest, opts ...grpc.CallOption) (*GetProofResponse, error) {
+	out := new(GetProofResponse)
+	err := c.cc.Invoke(ctx, Lanyard_GetProof_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lanyardClient) GetProofs(ctx context.Context, in *GetProofsRequest, opts ...grpc.CallOption) (*GetProofsResponse, error) {
+	out := new(GetProofsResponse)
+	err := c.cc.Invoke(ctx, Lanyard_GetProofs_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lanyardClient) GetRoots(ctx context.Context, in *GetRootsRequest, opts ...grpc.CallOption) (*GetRootsResponse, error) {
+	out := new(GetRootsResponse)
+	err := c.cc.Invoke(ctx, Lanyard_GetRoots_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lanyardClient) DeleteTree(ctx context.Context, in *DeleteTreeRequest, opts ...grpc.CallOption) (*DeleteTreeResponse, error) {
+	out := new(DeleteTreeResponse)
+	err := c.cc.Invoke(ctx, Lanyard_DeleteTree_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LanyardServer is the server API for Lanyard service.
+// All implementations must embed UnimplementedLanyardServer
+// for forward compatibility
+type LanyardServer interface {
+	// Creates a tree from unhashed leaves and returns its root.
+	// Creating a tree that already exists returns the same root.
+	CreateTree(context.Context, *CreateTreeRequest) (*CreateTreeResponse, error)
+	// Returns the leaves of a published tree. Private trees
+	// require their read token or their owner's API key.
+	GetTree(context.Context, *GetTreeRequest) (*GetTreeResponse, error)
+	// Searches the metadata of public trees.
+	ListTrees(context.Context, *ListTreesRequest) (*ListTreesResponse, error)
+	// Returns the proof for a leaf, or for the first
+	// leaf containing an address, in a published tree.
+	// Searching a private tree by address requires
+	// its read token or its owner's API key.
+	GetProof(context.Context, *GetProofRequest) (*GetProofResponse, error)
+	// Returns the proofs for many leaves in the same tree.
+	// Leaves that aren't in the tree are omitted.
+	GetProofs(context.Context, *GetProofsRequest) (*GetProofsResponse, error)
+	// Returns the roots of the published trees containing a proof.
+	// Private trees are never returned.
+	GetRoots(context.Context, *GetRootsRequest) (*GetRootsResponse, error)
+	// Deletes a tree created with the request's API key.
+	DeleteTree(context.Context, *DeleteTreeRequest) (*DeleteTreeResponse, error)
+	mustEmbedUnimplementedLanyardServer()
+}
+
+// UnimplementedLanyardServer must be embedded to have forward compatible implementations.
+type UnimplementedLanyardServer struct {
+}
+
+func (UnimplementedLanyardServer) CreateTree(context.Context, *CreateTreeRequest) (*CreateTreeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateTree not implemented")
+}
+func (UnimplementedLanyardServer) GetTree(context.Context, *GetTreeRequest) (*GetTreeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTree not implemented")
+}
+func (UnimplementedLanyardServer) ListTrees(context.Context, *ListTreesRequest) (*ListTreesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTrees not implemented")
+}
+func (UnimplementedLanyardServer) GetProof(context.Context, *GetProofRequest) (*GetProofResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProof not implemented")
+}
+func (UnimplementedLanyardServer) GetProofs(context.Context, *GetProofsRequest) (*GetProofsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProofs not implemented")
+}
+func (UnimplementedLanyardServer) GetRoots(context.Context, *GetRootsRequest) (*GetRootsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRoots not implemented")
+}
+func (UnimplementedLanyardServer) DeleteTree(context.Context, *DeleteTreeRequest) (*DeleteTreeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteTree not implemented")
+}
+func (UnimplementedLanyardServer) mustEmbedUnimplementedLanyardServer() {}
+
+// UnsafeLanyardServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LanyardServer will
+// result in compilation errors.
+type UnsafeLanyardServer interface {
+	mustEmbedUnimplementedLanyardServer()
+}
+
+func RegisterLanyardServer(s grpc.ServiceRegistrar, srv LanyardServer) {
+	s.RegisterService(&Lanyard_ServiceDesc, srv)
+}
+
+func _Lanyard_CreateTree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTreeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LanyardServer).CreateTree(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Lanyard_CreateTree_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LanyardServer).CreateTree(ctx, req.(*CreateTreeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lanyard_GetTree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTreeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LanyardServer).GetTree(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Lanyard_GetTree_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LanyardServer).GetTree(ctx, req.(*GetTreeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lanyard_ListTrees_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTreesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LanyardServer).ListTrees(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Lanyard_ListTrees_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LanyardServer).ListTrees(ctx, req.(*ListTreesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lanyard_GetProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LanyardServer).GetProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Lanyard_GetProof_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LanyardServer).GetProof(ctx, req.(*GetProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lanyard_GetProofs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProofsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LanyardServer).GetProofs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Lanyard_GetProofs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LanyardServer).GetProofs(ctx, req.(*GetProofsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lanyard_GetRoots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRootsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LanyardServer).GetRoots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Lanyard_GetRoots_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LanyardServer).GetRoots(ctx, req.(*GetRootsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lanyard_DeleteTree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteTreeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LanyardServer).DeleteTree(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Lanyard_DeleteTree_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LanyardServer).DeleteTree(ctx, req.(*DeleteTreeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Lanyard_ServiceDesc is the grpc.ServiceDesc for Lanyard service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Lanyard_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "lanyard.v1.Lanyard",
+	HandlerType: (*LanyardServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateTree",
+			Handler:    _Lanyard_CreateTree_Handler,
+		},
+		{
+			MethodName: "GetTree",
+			Handler:    _Lanyard_GetTree_Handler,
+		},
+		{
+			MethodName: "ListTrees",
+			Handler:    _Lanyard_ListTrees_Handler,
+		},
+		{
+			MethodName: "GetProof",
+			Handler:    _Lanyard_GetProof_Handler,
+		},
+		{
+			MethodName: "GetProofs",
+			Handler:    _Lanyard_GetProofs_Handler,
+		},
+		{
+			MethodName: "GetRoots",
+			Handler:    _Lanyard_GetRoots_Handler,
+		},
+		{
+			MethodName: "DeleteTree",
+			Handler:    _Lanyard_DeleteTree_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "lanyard.proto",
+}
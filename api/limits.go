@@ -0,0 +1,97 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Limits bound the size of the trees that can be created.
+// A zero value disables that limit.
+type Limits struct {
+	// MaxBodyBytes bounds the body of a create or verify
+	// request, and the size of a grpc message.
+	MaxBodyBytes int64 `json:"maxBodyBytes"`
+	MaxLeaves    int   `json:"maxLeaves"`
+
+	// MaxLeafBytes bounds each leaf after it is hex decoded.
+	MaxLeafBytes int `json:"maxLeafBytes"`
+
+	// MaxLtdLength bounds the number of types
+	// in a tree's leaf type descriptor.
+	MaxLtdLength int `json:"maxLeafTypeDescriptorLength"`
+}
+
+// DefaultLimits allow an allowlist of a million
+// addresses or abi encoded values.
+var DefaultLimits = Limits{
+	MaxBodyBytes: 128 << 20,
+	MaxLeaves:    1_000_000,
+	MaxLeafBytes: 1024,
+	MaxLtdLength: 32,
+}
+
+// WithLimits replaces [DefaultLimits].
+func WithLimits(l Limits) Option {
+	return func(s *Server) {
+		s.limits = l
+	}
+}
+
+func tooLarge(code, msg string) *apiError {
+	return &apiError{status: http.StatusRequestEntityTooLarge, code: code, msg: msg}
+}
+
+// Returns an error for the first limit exceeded by p.
+func (l Limits) checkTree(p treeParams) error {
+	if l.MaxLeaves > 0 && len(p.leaves) > l.MaxLeaves {
+		return tooLarge(codeTooManyLeaves, "too many leaves").with(map[string]any{
+			"count": len(p.leaves),
+			"limit": l.MaxLeaves,
+		})
+	}
+	if l.MaxLtdLength > 0 && len(p.ltd) > l.MaxLtdLength {
+		return badRequest(codeInvalidRequest, "leaf type descriptor is too long").with(map[string]any{
+			"field": "leafTypeDescriptor",
+			"limit": l.MaxLtdLength,
+		})
+	}
+	if l.MaxLeafBytes > 0 {
+		for i, leaf := range p.leaves {
+			if len(leaf) > l.MaxLeafBytes {
+				return badRequest(codeInvalidLeaf, "leaf is too long").with(map[string]any{
+					"index": i,
+					"limit": l.MaxLeafBytes,
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// Limits the body of r to MaxBodyBytes. Reading past
+// the limit returns an error that [decodeBodyError]
+// reports as a 413.
+func (l Limits) limitBody(w http.ResponseWriter, r *http.Request) {
+	if l.MaxBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, l.MaxBodyBytes)
+	}
+}
+
+// Returns the error for a request body that
+// couldn't be decoded.
+func decodeBodyError(err error) error {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return tooLarge(codeBodyTooLarge, "request body is too large").with(map[string]any{
+			"limit": mbe.Limit,
+		})
+	}
+	return invalidBody(err)
+}
+
+// Limits handles GET /api/v1/limits so that clients
+// can check a tree before creating it.
+func (s *Server) Limits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	s.sendJSON(r, w, s.limits)
+}
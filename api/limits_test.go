@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateTreeLimits(t *testing.T) {
+	s := &Server{
+		tlru: newTreeCache(0, 0),
+		limits: Limits{
+			MaxBodyBytes: 200,
+			MaxLeaves:    2,
+			MaxLeafBytes: 2,
+			MaxLtdLength: 1,
+		},
+	}
+	cases := []struct {
+		desc   string
+		body   string
+		status int
+		code   string
+	}{
+		{
+			desc:   "body",
+			body:   `{"unhashedLeaves": ["0x` + strings.Repeat("00", 100) + `"]}`,
+			status: http.StatusRequestEntityTooLarge,
+			code:   codeBodyTooLarge,
+		},
+		{
+			desc:   "leaves",
+			body:   `{"unhashedLeaves": ["0x01", "0x02", "0x03"]}`,
+			status: http.StatusRequestEntityTooLarge,
+			code:   codeTooManyLeaves,
+		},
+		{
+			desc:   "leaf",
+			body:   `{"unhashedLeaves": ["0x01", "0x020202"]}`,
+			status: http.StatusBadRequest,
+			code:   codeInvalidLeaf,
+		},
+		{
+			desc:   "ltd",
+			body:   `{"unhashedLeaves": ["0x01", "0x02"], "leafTypeDescriptor": ["uint8", "uint8"]}`,
+			status: http.StatusBadRequest,
+			code:   codeInvalidRequest,
+		},
+	}
+	for _, c := range cases {
+		var (
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest("POST", "/api/v1/tree", strings.NewReader(c.body))
+		)
+		s.Handler("production", "").ServeHTTP(w, r)
+
+		var resp errorResp
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != c.status || resp.Code != c.code {
+			t.Errorf("%s: expected %d %s, got %d %s", c.desc, c.status, c.code, w.Code, resp.Code)
+		}
+		if resp.Details["limit"] == nil {
+			t.Errorf("%s: expected the limit in the details", c.desc)
+		}
+	}
+}
+
+func TestLimitsHandler(t *testing.T) {
+	var (
+		s = &Server{limits: DefaultLimits}
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest("GET", "/api/v1/limits", nil)
+	)
+	s.Handler("production", "").ServeHTTP(w, r)
+
+	var got Limits
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != DefaultLimits {
+		t.Errorf("expected %+v, got %+v", DefaultLimits, got)
+	}
+}
@@ -0,0 +1,367 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/jackc/pgx/v4"
+)
+
+// Optional descriptive fields of a tree, stored in
+// tree_metadata when the tree is created.
+type treeMetadata struct {
+	Name            string        `json:"name,omitempty"`
+	Description     string        `json:"description,omitempty"`
+	Tags            []string      `json:"tags,omitempty"`
+	ChainID         int64         `json:"chainId,omitempty"`
+	ContractAddress hexutil.Bytes `json:"contractAddress,omitempty"`
+
+	// the window in which the tree's leaves can be claimed
+	ClaimStart *time.Time `json:"claimStart,omitempty"`
+	ClaimEnd   *time.Time `json:"claimEnd,omitempty"`
+}
+
+const (
+	maxNameLen        = 256
+	maxDescriptionLen = 4096
+	maxTags           = 32
+	maxTagLen         = 64
+)
+
+func (m *treeMetadata) validate() error {
+	invalid := func(field, reason string) error {
+		return badRequest(codeInvalidRequest, "invalid metadata").with(map[string]any{
+			"field":  field,
+			"reason": reason,
+		})
+	}
+	switch {
+	case len(m.Name) > maxNameLen:
+		return invalid("name", "must be at most "+strconv.Itoa(maxNameLen)+" bytes")
+	case len(m.Description) > maxDescriptionLen:
+		return invalid("description", "must be at most "+strconv.Itoa(maxDescriptionLen)+" bytes")
+	case len(m.Tags) > maxTags:
+		return invalid("tags", "must have at most "+strconv.Itoa(maxTags)+" tags")
+	case m.ContractAddress != nil && len(m.ContractAddress) != common.AddressLength:
+		return invalid("contractAddress", "must be 20 bytes")
+	case m.ChainID < 0:
+		return invalid("chainId", "must not be negative")
+	case m.ClaimStart != nil && m.ClaimEnd != nil && !m.ClaimEnd.After(*m.ClaimStart):
+		return invalid("claimEnd", "must be after claimStart")
+	}
+	for _, t := range m.Tags {
+		if t == "" || len(t) > maxTagLen {
+			return invalid("tags", "tags must be 1 to "+strconv.Itoa(maxTagLen)+" bytes")
+		}
+	}
+	return nil
+}
+
+// Inserts the metadata of the tree with root, and reports
+// whether it was inserted, which it isn't if the tree
+// already has metadata.
+func insertTreeMetadata(ctx context.Context, tx pgx.Tx, root []byte, m *treeMetadata) (bool, error) {
+	const q = `
+		-- name: InsertTreeMetadata
+		INSERT INTO tree_metadata (
+			root,
+			name,
+			description,
+			tags,
+			chain_id,
+			contract_address,
+			claim_starts_at,
+			claim_ends_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (root)
+		DO NOTHING
+	`
+	tags := m.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	tag, err := tx.Exec(ctx, q,
+		root,
+		m.Name,
+		m.Description,
+		tags,
+		m.ChainID,
+		[]byte(m.ContractAddress),
+		m.ClaimStart,
+		m.ClaimEnd,
+	)
+	return tag.RowsAffected() == 1, err
+}
+
+// Adds metadata m to an existing tree, with owner and the
+// metadata prev, if it has none. Sending the tree's metadata
+// again is allowed so that creating a tree can be retried, but
+// different metadata is a conflict since it can't be changed.
+// Only the owner of an owned tree can add its metadata.
+func (s *Server) addTreeMetadata(ctx context.Context, root []byte, owner *string, prev, m *treeMetadata) error {
+	if prev != nil {
+		if !prev.equal(m) {
+			return conflict(codeTreeExists, "tree already exists with different metadata")
+		}
+		return nil
+	}
+	if k, ok := apiKeyFromContext(ctx); owner != nil && (!ok || k.Name != *owner) {
+		return &apiError{
+			status: http.StatusForbidden,
+			code:   codeForbidden,
+			msg:    "only the owner of the tree can add its metadata",
+		}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return internalError(err, "creating transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	inserted, err := insertTreeMetadata(ctx, tx, root, m)
+	if err != nil {
+		return internalError(err, "inserting metadata")
+	}
+	if !inserted {
+		// added by a concurrent request
+		return conflict(codeTreeExists, "tree already exists with different metadata")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return internalError(err, "committing transaction")
+	}
+	return nil
+}
+
+// Reports whether m and o are the same metadata as
+// stored, which keeps timestamps to the microsecond.
+func (m *treeMetadata) equal(o *treeMetadata) bool {
+	sameTime := func(a, b *time.Time) bool {
+		if a == nil || b == nil {
+			return a == b
+		}
+		return a.Truncate(time.Microsecond).Equal(b.Truncate(time.Microsecond))
+	}
+	if len(m.Tags) != len(o.Tags) {
+		return false
+	}
+	for i := range m.Tags {
+		if m.Tags[i] != o.Tags[i] {
+			return false
+		}
+	}
+	return m.Name == o.Name &&
+		m.Description == o.Description &&
+		m.ChainID == o.ChainID &&
+		bytes.Equal(m.ContractAddress, o.ContractAddress) &&
+		sameTime(m.ClaimStart, o.ClaimStart) &&
+		sameTime(m.ClaimEnd, o.ClaimEnd)
+}
+
+// The columns of tree_metadata m scanned by metadataScanner.
+const metadataColumns = `
+	m.root IS NOT NULL,
+	m.name,
+	m.description,
+	m.tags,
+	m.chain_id,
+	m.contract_address,
+	m.claim_starts_at,
+	m.claim_ends_at
+`
+
+// Scans the nullable columns of a left join
+// on tree_metadata. See metadataColumns.
+type metadataScanner struct {
+	ok          bool
+	name        *string
+	description *string
+	tags        []string
+	chainID     *int64
+	contract    []byte
+	start, end  *time.Time
+}
+
+func (ms *metadataScanner) dest() []any {
+	return []any{
+		&ms.ok,
+		&ms.name,
+		&ms.description,
+		&ms.tags,
+		&ms.chainID,
+		&ms.contract,
+		&ms.start,
+		&ms.end,
+	}
+}
+
+// Returns the scanned metadata, or nil
+// if the tree doesn't have any.
+func (ms *metadataScanner) metadata() *treeMetadata {
+	if !ms.ok {
+		return nil
+	}
+	m := &treeMetadata{
+		Tags:            ms.tags,
+		ContractAddress: ms.contract,
+		ClaimStart:      ms.start,
+		ClaimEnd:        ms.end,
+	}
+	if ms.name != nil {
+		m.Name = *ms.name
+	}
+	if ms.description != nil {
+		m.Description = *ms.description
+	}
+	if ms.chainID != nil {
+		m.ChainID = *ms.chainID
+	}
+	if len(m.Tags) == 0 {
+		m.Tags = nil
+	}
+	return m
+}
+
+type treeSummary struct {
+	MerkleRoot hexutil.Bytes `json:"merkleRoot"`
+	Metadata   treeMetadata  `json:"metadata"`
+	InsertedAt time.Time     `json:"insertedAt"`
+}
+
+type listTreesResp struct {
+	Trees []treeSummary `json:"trees"`
+}
+
+// Filters for listing trees. Zero values match every tree.
+type treeFilter struct {
+	tags     []string
+	contract []byte
+	chainID  int64
+	limit    int
+	offset   int
+}
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// Returns the unexpired public trees with metadata
+// matching f, most recently created first.
+func (s *Server) listTrees(ctx context.Context, f treeFilter) ([]treeSummary, error) {
+	const q = `
+		-- name: ListTrees
+		SELECT t.root, t.inserted_at, ` + metadataColumns + `
+		FROM tree_metadata m
+		JOIN trees t ON t.root = m.root
+		WHERE NOT t.private
+		AND (t.expires_at IS NULL OR t.expires_at > now())
+		AND m.tags @> $1
+		AND ($2::bytea IS NULL OR m.contract_address = $2)
+		AND ($3::bigint = 0 OR m.chain_id = $3)
+		ORDER BY t.inserted_at DESC, t.root
+		LIMIT $4 OFFSET $5
+	`
+	tags := f.tags
+	if tags == nil {
+		tags = []string{}
+	}
+	var (
+		trees []treeSummary
+		ts    treeSummary
+		ms    metadataScanner
+	)
+	_, err := s.db.QueryFunc(ctx, q,
+		[]any{tags, f.contract, f.chainID, f.limit, f.offset},
+		append([]any{&ts.MerkleRoot, &ts.InsertedAt}, ms.dest()...),
+		func(pgx.QueryFuncRow) error {
+			ts.Metadata = *ms.metadata()
+			trees = append(trees, ts)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, internalError(err, "selecting trees")
+	}
+	return trees, nil
+}
+
+// Parses the query of GET /api/v1/trees.
+func parseTreeFilter(r *http.Request) (treeFilter, error) {
+	var (
+		q = r.URL.Query()
+		f = treeFilter{
+			tags: q["tag"],
+		}
+		invalid = func(param string) error {
+			return badRequest(codeInvalidRequest, "invalid "+param)
+		}
+	)
+	if c := q.Get("contract"); c != "" {
+		if !common.IsHexAddress(c) {
+			return f, invalid("contract")
+		}
+		f.contract = common.HexToAddress(c).Bytes()
+	}
+	for param, dst := range map[string]*int{
+		"limit":  &f.limit,
+		"offset": &f.offset,
+	} {
+		if v := q.Get(param); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return f, invalid(param)
+			}
+			*dst = n
+		}
+	}
+	if v := q.Get("chainId"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return f, invalid("chainId")
+		}
+		f.chainID = n
+	}
+	return f.withLimit(), nil
+}
+
+// Returns f with the default limit if none was
+// requested or the max limit if it is exceeded.
+func (f treeFilter) withLimit() treeFilter {
+	switch {
+	case f.limit <= 0:
+		f.limit = defaultListLimit
+	case f.limit > maxListLimit:
+		f.limit = maxListLimit
+	}
+	return f
+}
+
+// ListTrees handles GET /api/v1/trees, which searches the
+// metadata of public trees by tag, contract and chain.
+func (s *Server) ListTrees(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
+		return
+	}
+	f, err := parseTreeFilter(r)
+	if err != nil {
+		s.sendJSONError(r, w, err)
+		return
+	}
+	trees, err := s.listTrees(r.Context(), f)
+	if err != nil {
+		s.sendJSONError(r, w, err)
+		return
+	}
+	if trees == nil {
+		trees = []treeSummary{}
+	}
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	s.sendJSON(r, w, listTreesResp{Trees: trees})
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestTreeMetadataValidate(t *testing.T) {
+	var (
+		start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		end   = start.Add(time.Hour)
+	)
+	cases := []struct {
+		m     treeMetadata
+		field string
+	}{
+		{
+			m: treeMetadata{
+				Name:            "allowlist",
+				Tags:            []string{"mint"},
+				ChainID:         1,
+				ContractAddress: common.Address{1}.Bytes(),
+				ClaimStart:      &start,
+				ClaimEnd:        &end,
+			},
+		},
+		{m: treeMetadata{Name: strings.Repeat("a", maxNameLen+1)}, field: "name"},
+		{m: treeMetadata{Tags: []string{""}}, field: "tags"},
+		{m: treeMetadata{Tags: make([]string, maxTags+1)}, field: "tags"},
+		{m: treeMetadata{ChainID: -1}, field: "chainId"},
+		{m: treeMetadata{ChainID: 0}},
+		{m: treeMetadata{ContractAddress: []byte{1}}, field: "contractAddress"},
+		{m: treeMetadata{ClaimStart: &end, ClaimEnd: &start}, field: "claimEnd"},
+	}
+	for _, c := range cases {
+		err := c.m.validate()
+		if c.field == "" {
+			if err != nil {
+				t.Errorf("expected %+v to be valid, got %v", c.m, err)
+			}
+			continue
+		}
+		ae, ok := err.(*apiError)
+		if !ok || ae.details["field"] != c.field {
+			t.Errorf("expected invalid %s, got %v", c.field, err)
+		}
+	}
+}
+
+func TestParseTreeFilter(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/v1/trees?tag=a&tag=b&contract=0x0000000000000000000000000000000000000001&chainId=10&limit=5000", nil)
+	f, err := parseTreeFilter(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.tags) != 2 || f.chainID != 10 || f.limit != maxListLimit || f.offset != 0 {
+		t.Errorf("unexpected filter %+v", f)
+	}
+	if common.BytesToAddress(f.contract) != common.HexToAddress("0x01") {
+		t.Errorf("unexpected contract %x", f.contract)
+	}
+
+	for _, q := range []string{"contract=0x01", "limit=-1", "offset=a", "chainId=-1"} {
+		if _, err := parseTreeFilter(httptest.NewRequest("GET", "/api/v1/trees?"+q, nil)); err == nil {
+			t.Errorf("expected %s to be rejected", q)
+		}
+	}
+}
+
+func TestTreeMetadataEqual(t *testing.T) {
+	var (
+		start = time.Date(2026, 1, 1, 0, 0, 0, 1500, time.UTC)
+		// as read back from postgres
+		stored = start.Truncate(time.Microsecond).Local()
+		other  = start.Add(time.Second)
+	)
+	m := &treeMetadata{Name: "allowlist", Tags: []string{"mint"}, ChainID: 1, ClaimStart: &start}
+	cases := []struct {
+		o    *treeMetadata
+		want bool
+	}{
+		{&treeMetadata{Name: "allowlist", Tags: []string{"mint"}, ChainID: 1, ClaimStart: &stored}, true},
+		{&treeMetadata{Name: "allowlist", Tags: []string{"mint"}, ChainID: 1, ClaimStart: &other}, false},
+		{&treeMetadata{Name: "allowlist", Tags: []string{"mint"}, ChainID: 1}, false},
+		{&treeMetadata{Name: "allowlist", Tags: []string{"burn"}, ChainID: 1, ClaimStart: &start}, false},
+		{&treeMetadata{Name: "allowlist", ChainID: 1, ClaimStart: &start}, false},
+		{&treeMetadata{Name: "allowlist", Tags: []string{"mint"}, ChainID: 10, ClaimStart: &start}, false},
+	}
+	for i, c := range cases {
+		if got := m.equal(c.o); got != c.want {
+			t.Errorf("case %d: expected %t, got %t", i, c.want, got)
+		}
+	}
+}
+
+func TestAddExistingTreeMetadata(t *testing.T) {
+	var (
+		s    = &Server{}
+		ctx  = context.Background()
+		prev = &treeMetadata{Name: "allowlist"}
+	)
+	if err := s.addTreeMetadata(ctx, nil, nil, prev, &treeMetadata{Name: "allowlist"}); err != nil {
+		t.Errorf("expected the same metadata to be accepted, got %v", err)
+	}
+	err := s.addTreeMetadata(ctx, nil, nil, prev, &treeMetadata{Name: "other"})
+	if ae, ok := err.(*apiError); !ok || ae.code != codeTreeExists {
+		t.Errorf("expected %s, got %v", codeTreeExists, err)
+	}
+	owner := "writer"
+	err = s.addTreeMetadata(ctx, nil, &owner, nil, prev)
+	if ae, ok := err.(*apiError); !ok || ae.code != codeForbidden {
+		t.Errorf("expected %s, got %v", codeForbidden, err)
+	}
+}
+
+func TestListTreesExpired(t *testing.T) {
+	var (
+		s   = testDBServer(t)
+		ctx = context.Background()
+		tag = common.Bytes2Hex(randomLeaves(t, 1)[0])
+		exp = time.Now().Add(time.Hour)
+	)
+	tr, err := s.createTree(ctx, treeParams{
+		leaves:    randomLeaves(t, 2),
+		metadata:  &treeMetadata{Tags: []string{tag}},
+		expiresAt: &exp,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := func() int {
+		trees, err := s.listTrees(ctx, treeFilter{tags: []string{tag}}.withLimit())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(trees)
+	}
+	if n := list(); n != 1 {
+		t.Fatalf("expected the tree to be listed, got %d trees", n)
+	}
+
+	const q = `UPDATE trees SET expires_at = now() - interval '1 second' WHERE root = $1`
+	if _, err := s.db.Exec(ctx, q, tr.root); err != nil {
+		t.Fatal(err)
+	}
+	if n := list(); n != 0 {
+		t.Errorf("expected the expired tree not to be listed, got %d trees", n)
+	}
+}
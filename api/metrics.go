@@ -0,0 +1,211 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics are registered on a registry per server, rather than
+// the global one, so that tests can create many servers.
+// A nil *metrics records nothing.
+type metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	buildDuration   *prometheus.HistogramVec
+	buildLeaves     *prometheus.HistogramVec
+}
+
+func newMetrics(s *Server) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "lanyard",
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "lanyard",
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		buildDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "lanyard",
+			Name:      "tree_build_duration_seconds",
+			Help:      "Time to build a tree when it is created or loaded into the cache.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		}, []string{"source"}),
+		buildLeaves: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "lanyard",
+			Name:      "tree_build_leaves",
+			Help:      "Leaves in each tree built when it is created or loaded into the cache.",
+			Buckets:   prometheus.ExponentialBuckets(2, 4, 10),
+		}, []string{"source"}),
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.buildDuration,
+		m.buildLeaves,
+		serverCollector{s},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Where a tree was built.
+const (
+	buildCreate = "create"
+	buildLoad   = "load"
+)
+
+func (m *metrics) observeBuild(source string, leaves int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.buildDuration.WithLabelValues(source).Observe(d.Seconds())
+	m.buildLeaves.WithLabelValues(source).Observe(float64(leaves))
+}
+
+// handler records every request by its route. Paths that
+// aren't routes are recorded as "other" so that scanners can't
+// create unbounded label values.
+func (m *metrics) handler(routes map[string]bool, h http.Handler) http.Handler {
+	if m == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if !routes[route] {
+			route = "other"
+		}
+
+		sc := &statusCapture{ResponseWriter: w}
+		start := time.Now()
+		h.ServeHTTP(sc, r)
+
+		status := sc.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{
+			"route":  route,
+			"method": r.Method,
+			"status": strconv.Itoa(status),
+		}
+		m.requests.With(labels).Inc()
+		m.requestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// MetricsHandler serves the server's metrics in the
+// Prometheus exposition format without authentication,
+// for a listener that isn't public. [Server.Handler]
+// serves it at /metrics to admins.
+func (s *Server) MetricsHandler() http.Handler {
+	if s.metrics == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(s.metrics.registry, promhttp.HandlerOpts{})
+}
+
+// serverCollector reads the tree cache and db pool
+// stats when the metrics are scraped.
+type serverCollector struct {
+	s *Server
+}
+
+var (
+	cacheHitsDesc = prometheus.NewDesc(
+		"lanyard_tree_cache_hits_total",
+		"Tree cache lookups that found the tree.", nil, nil,
+	)
+	cacheMissesDesc = prometheus.NewDesc(
+		"lanyard_tree_cache_misses_total",
+		"Tree cache lookups that didn't find the tree.", nil, nil,
+	)
+	cacheEvictionsDesc = prometheus.NewDesc(
+		"lanyard_tree_cache_evictions_total",
+		"Trees evicted from the tree cache to stay within its bounds.", nil, nil,
+	)
+	cacheEntriesDesc = prometheus.NewDesc(
+		"lanyard_tree_cache_entries",
+		"Trees in the tree cache.", nil, nil,
+	)
+	cacheBytesDesc = prometheus.NewDesc(
+		"lanyard_tree_cache_bytes",
+		"Approximate bytes of leaves and levels in the tree cache.", nil, nil,
+	)
+	poolAcquiresDesc = prometheus.NewDesc(
+		"lanyard_db_pool_acquires_total",
+		"Connections acquired from the db pool.", nil, nil,
+	)
+	poolAcquireDurationDesc = prometheus.NewDesc(
+		"lanyard_db_pool_acquire_duration_seconds_total",
+		"Time spent acquiring connections from the db pool.", nil, nil,
+	)
+	poolEmptyAcquiresDesc = prometheus.NewDesc(
+		"lanyard_db_pool_empty_acquires_total",
+		"Acquires that waited for a connection because the db pool was empty.", nil, nil,
+	)
+	poolCanceledAcquiresDesc = prometheus.NewDesc(
+		"lanyard_db_pool_canceled_acquires_total",
+		"Acquires canceled by their context.", nil, nil,
+	)
+	poolConnsDesc = prometheus.NewDesc(
+		"lanyard_db_pool_conns",
+		"Connections in the db pool by state.", []string{"state"}, nil,
+	)
+	poolMaxConnsDesc = prometheus.NewDesc(
+		"lanyard_db_pool_max_conns",
+		"Max connections in the db pool.", nil, nil,
+	)
+)
+
+func (c serverCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		cacheHitsDesc,
+		cacheMissesDesc,
+		cacheEvictionsDesc,
+		cacheEntriesDesc,
+		cacheBytesDesc,
+		poolAcquiresDesc,
+		poolAcquireDurationDesc,
+		poolEmptyAcquiresDesc,
+		poolCanceledAcquiresDesc,
+		poolConnsDesc,
+		poolMaxConnsDesc,
+	} {
+		ch <- d
+	}
+}
+
+func (c serverCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.s.tlru != nil {
+		cs := c.s.tlru.Stats()
+		ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(cs.Hits))
+		ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(cs.Misses))
+		ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(cs.Evictions))
+		ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(cs.Entries))
+		ch <- prometheus.MustNewConstMetric(cacheBytesDesc, prometheus.GaugeValue, float64(cs.Bytes))
+	}
+	if c.s.db != nil {
+		ps := c.s.db.Stat()
+		ch <- prometheus.MustNewConstMetric(poolAcquiresDesc, prometheus.CounterValue, float64(ps.AcquireCount()))
+		ch <- prometheus.MustNewConstMetric(poolAcquireDurationDesc, prometheus.CounterValue, ps.AcquireDuration().Seconds())
+		ch <- prometheus.MustNewConstMetric(poolEmptyAcquiresDesc, prometheus.CounterValue, float64(ps.EmptyAcquireCount()))
+		ch <- prometheus.MustNewConstMetric(poolCanceledAcquiresDesc, prometheus.CounterValue, float64(ps.CanceledAcquireCount()))
+		ch <- prometheus.MustNewConstMetric(poolConnsDesc, prometheus.GaugeValue, float64(ps.AcquiredConns()), "acquired")
+		ch <- prometheus.MustNewConstMetric(poolConnsDesc, prometheus.GaugeValue, float64(ps.IdleConns()), "idle")
+		ch <- prometheus.MustNewConstMetric(poolConnsDesc, prometheus.GaugeValue, float64(ps.ConstructingConns()), "constructing")
+		ch <- prometheus.MustNewConstMetric(poolMaxConnsDesc, prometheus.GaugeValue, float64(ps.MaxConns()))
+	}
+}
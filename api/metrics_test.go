@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/contextwtf/lanyard/merkle"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestMetrics(t *testing.T) {
+	td := getTreeResp{
+		UnhashedLeaves: []hexutil.Bytes{{0x01}, {0x02}, {0x03}},
+	}
+	s := New(nil, WithAdminToken("admin"))
+	s.loadTree = func(ctx context.Context, root []byte) (getTreeResp, merkle.Tree, error) {
+		return td, nil, nil
+	}
+	var (
+		h     = s.Handler("production", "")
+		root  = hexutil.Bytes(newCachedTree(td, nil).t.Root())
+		proof = "/api/v1/proof?" + url.Values{
+			"root":         {root.String()},
+			"unhashedLeaf": {"0x01"},
+		}.Encode()
+	)
+	for _, path := range []string{proof, proof, "/wp-login.php"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without the admin token, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	r.Header.Set("Authorization", "Bearer admin")
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	body, _ := io.ReadAll(w.Body)
+	for _, want := range []string{
+		`lanyard_http_requests_total{method="GET",route="/api/v1/proof",status="200"} 2`,
+		`lanyard_http_requests_total{method="GET",route="other",status="404"} 1`,
+		`lanyard_http_request_duration_seconds_count{method="GET",route="/api/v1/proof",status="200"} 2`,
+		`lanyard_tree_cache_hits_total 1`,
+		`lanyard_tree_cache_misses_total 1`,
+		`lanyard_tree_cache_entries 1`,
+		`lanyard_tree_build_leaves_sum{source="load"} 3`,
+		`lanyard_tree_build_duration_seconds_count{source="load"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected %s", want)
+		}
+	}
+}
@@ -133,4 +133,61 @@ var Migrations = []migrate.Migration{
 		DROP TABLE "trees_proofs";
 		`,
 	},
+	{
+		Name: "2026-10-19.0.tree-levels.sql",
+		SQL: `
+		ALTER TABLE trees
+		ADD COLUMN levels bytea;
+		`,
+	},
+	{
+		Name: "2026-10-19.1.api-keys.sql",
+		SQL: `
+		CREATE TABLE api_keys (
+			name text PRIMARY KEY,
+			key_hash bytea NOT NULL UNIQUE,
+			scopes text[] NOT NULL DEFAULT '{}',
+			inserted_at timestamptz NOT NULL DEFAULT now(),
+			revoked_at timestamptz
+		);
+		ALTER TABLE trees
+		ADD COLUMN owner text;
+		CREATE INDEX trees_owner_idx ON trees (owner);
+		`,
+	},
+	{
+		Name: "2026-10-19.2.private-trees.sql",
+		SQL: `
+		ALTER TABLE trees
+		ADD COLUMN private boolean NOT NULL DEFAULT false,
+		ADD COLUMN read_token_hash bytea;
+		`,
+	},
+	{
+		Name: "2026-10-19.3.tree-metadata.sql",
+		SQL: `
+		CREATE TABLE tree_metadata (
+			root bytea PRIMARY KEY REFERENCES trees (root) ON DELETE CASCADE,
+			name text NOT NULL DEFAULT '',
+			description text NOT NULL DEFAULT '',
+			tags text[] NOT NULL DEFAULT '{}',
+			chain_id bigint NOT NULL DEFAULT 0,
+			contract_address bytea,
+			claim_starts_at timestamptz,
+			claim_ends_at timestamptz
+		);
+		CREATE INDEX tree_metadata_tags_idx ON tree_metadata USING GIN (tags);
+		CREATE INDEX tree_metadata_contract_idx ON tree_metadata (contract_address);
+		`,
+	},
+	{
+		Name: "2026-10-19.4.tree-expiry.sql",
+		SQL: `
+		ALTER TABLE trees
+		ADD COLUMN expires_at timestamptz;
+		CREATE INDEX trees_expires_at_idx ON trees (expires_at)
+		WHERE expires_at IS NOT NULL;
+		CREATE INDEX proofs_hashes_root_idx ON proofs_hashes (root);
+		`,
+	},
 }
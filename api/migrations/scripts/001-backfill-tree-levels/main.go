@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/contextwtf/lanyard/merkle"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"golang.org/x/sync/errgroup"
+)
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "processor error: %s", err)
+		debug.PrintStack()
+		os.Exit(1)
+	}
+}
+
+// trees are loaded and updated in batches
+// to bound memory use on large trees
+const batchSize = 100
+
+type tree struct {
+	root   []byte
+	leaves [][]byte
+	levels []byte
+}
+
+func main() {
+	ctx := context.Background()
+	const defaultPGURL = "postgres:///al"
+	dburl := os.Getenv("DATABASE_URL")
+	if dburl == "" {
+		dburl = defaultPGURL
+	}
+	dbc, err := pgxpool.ParseConfig(dburl)
+	check(err)
+
+	db, err := pgxpool.ConnectConfig(ctx, dbc)
+	check(err)
+
+	var count int
+	for {
+		const q = `
+			SELECT root, unhashed_leaves
+			FROM trees
+			WHERE levels IS NULL
+			LIMIT $1
+		`
+		rows, err := db.Query(ctx, q, batchSize)
+		check(err)
+
+		trees := []*tree{}
+		for rows.Next() {
+			t := &tree{}
+			check(rows.Scan(&t.root, &t.leaves))
+			trees = append(trees, t)
+		}
+		check(rows.Err())
+		rows.Close()
+
+		if len(trees) == 0 {
+			break
+		}
+
+		var eg errgroup.Group
+		eg.SetLimit(runtime.NumCPU())
+		for _, t := range trees {
+			t := t
+			eg.Go(func() error {
+				var err error
+				t.levels, err = merkle.NewParallel(t.leaves).MarshalBinary()
+				return err
+			})
+		}
+		check(eg.Wait())
+
+		tx, err := db.Begin(ctx)
+		check(err)
+		for _, t := range trees {
+			const q = `
+				UPDATE trees
+				SET levels = $2
+				WHERE root = $1
+			`
+			_, err = tx.Exec(ctx, q, t.root, t.levels)
+			check(err)
+		}
+		check(tx.Commit(ctx))
+
+		count += len(trees)
+		log.Printf("backfilled %d trees", count)
+	}
+
+	log.Printf("done")
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// The OpenAPI 3 description of the /api/v1 endpoints.
+// openapi_test.go checks its schemas against the
+// request and response types.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+type openAPISchema struct {
+	Ref        string                    `json:"$ref,omitempty"`
+	Type       string                    `json:"type,omitempty"`
+	Items      *openAPISchema            `json:"items,omitempty"`
+	Properties map[string]*openAPISchema `json:"properties,omitempty"`
+	Enum       []string                  `json:"enum,omitempty"`
+}
+
+// The schemas checked against the request and response types.
+// Fields of these types are refs to their schemas.
+var openAPITypes = map[string]any{
+	"CreateTreeRequest":  createTreeReq{},
+	"CreateTreeResponse": createTreeResp{},
+	"GetTreeResponse":    getTreeResp{},
+	"TreeMetadata":       treeMetadata{},
+	"TreeSummary":        treeSummary{},
+	"ListTreesResponse":  listTreesResp{},
+	"GetProofResponse":   getProofResp{},
+	"GetRootResponse":    rootResp{},
+	"GetRootsResponse":   rootsResp{},
+	"VerifyRequest":      verifyReq{},
+	"VerifyResponse":     verifyResp{},
+	"Limits":             Limits{},
+	"Error":              errorResp{},
+}
+
+// Returns the schema type that encoding/json uses for t.
+// Hex encoded types are refs to the Hex schema.
+func openAPIType(t reflect.Type) openAPISchema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for name, v := range openAPITypes {
+		if reflect.TypeOf(v) == t {
+			return openAPISchema{Ref: "#/components/schemas/" + name}
+		}
+	}
+	switch {
+	case t == reflect.TypeOf(hexutil.Bytes{}):
+		return openAPISchema{Ref: "#/components/schemas/Hex"}
+	case t == reflect.TypeOf(time.Time{}):
+		return openAPISchema{Type: "string"}
+	case t.Kind() == reflect.Slice:
+		items := openAPIType(t.Elem())
+		return openAPISchema{Type: "array", Items: &items}
+	case t.Kind() == reflect.Map:
+		return openAPISchema{Type: "object"}
+	case t.Kind() == reflect.Bool:
+		return openAPISchema{Type: "boolean"}
+	case t.Kind() == reflect.Int, t.Kind() == reflect.Int64:
+		return openAPISchema{Type: "integer"}
+	case t.Kind() == reflect.String:
+		return openAPISchema{Type: "string"}
+	case t.Kind() == reflect.Struct:
+		return openAPISchema{Type: "object"}
+	}
+	return openAPISchema{Type: t.String()}
+}
+
+// Strings may be documented as Hex, but hexutil.Bytes must be.
+func sameType(got *openAPISchema, want openAPISchema) bool {
+	if got != nil && want.Type == "string" && got.Ref == "#/components/schemas/Hex" {
+		return true
+	}
+	if got == nil || got.Ref != want.Ref || got.Type != want.Type {
+		return false
+	}
+	if want.Items != nil {
+		return sameType(got.Items, *want.Items)
+	}
+	return true
+}
+
+// Returns the json fields of the struct type t by name,
+// including the fields of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			for k, v := range jsonFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		fields[tag] = f.Type
+	}
+	return fields
+}
+
+// Checks that got documents values of type rt, following refs
+// to registered types and recursing into struct fields and
+// slice items. Each registered type is checked once.
+func checkSchema(t *testing.T, schemas map[string]*openAPISchema, path string, got *openAPISchema, rt reflect.Type, seen map[string]bool) {
+	if rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	want := openAPIType(rt)
+	if !sameType(got, want) {
+		wj, _ := json.Marshal(want)
+		gj, _ := json.Marshal(got)
+		t.Errorf("%s: expected %s, got %s", path, wj, gj)
+		return
+	}
+	switch {
+	case strings.HasPrefix(want.Ref, "#/components/schemas/") && rt.Kind() == reflect.Struct:
+		name := strings.TrimPrefix(want.Ref, "#/components/schemas/")
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		schema, ok := schemas[name]
+		if !ok {
+			t.Errorf("missing schema %s", name)
+			return
+		}
+		checkProperties(t, schemas, name, schema, rt, seen)
+	case want.Type == "object" && rt.Kind() == reflect.Struct:
+		checkProperties(t, schemas, path, got, rt, seen)
+	case want.Items != nil:
+		checkSchema(t, schemas, path+"[]", got.Items, rt.Elem(), seen)
+	}
+}
+
+func checkProperties(t *testing.T, schemas map[string]*openAPISchema, path string, schema *openAPISchema, rt reflect.Type, seen map[string]bool) {
+	fields := jsonFields(rt)
+	for tag, ft := range fields {
+		checkSchema(t, schemas, path+"."+tag, schema.Properties[tag], ft, seen)
+	}
+	for p := range schema.Properties {
+		if _, ok := fields[p]; !ok {
+			t.Errorf("%s.%s is not a field of %s", path, p, rt)
+		}
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	var spec struct {
+		Components struct {
+			Schemas map[string]*openAPISchema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for name, v := range openAPITypes {
+		ref := &openAPISchema{Ref: "#/components/schemas/" + name}
+		checkSchema(t, spec.Components.Schemas, name, ref, reflect.TypeOf(v), seen)
+	}
+}
+
+// Error details depend on the code and are free-form,
+// but every code must be documented.
+func TestOpenAPIErrorCodes(t *testing.T) {
+	var spec struct {
+		Components struct {
+			Schemas map[string]*openAPISchema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+	documented := map[string]bool{}
+	for _, c := range spec.Components.Schemas["Error"].Properties["code"].Enum {
+		documented[c] = true
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), "errors.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := map[string]bool{}
+	for _, obj := range f.Scope.Objects {
+		if obj.Kind != ast.Con || !strings.HasPrefix(obj.Name, "code") {
+			continue
+		}
+		vs := obj.Decl.(*ast.ValueSpec)
+		lit, ok := vs.Values[0].(*ast.BasicLit)
+		if !ok {
+			continue
+		}
+		code, _ := strconv.Unquote(lit.Value)
+		codes[code] = true
+		if !documented[code] {
+			t.Errorf("error code %s is not documented", code)
+		}
+	}
+	for c := range documented {
+		if !codes[c] {
+			t.Errorf("documented error code %s is not used", c)
+		}
+	}
+}
+
+// Every api route is documented, and every documented path is
+// a route. The spec doesn't document itself.
+func TestOpenAPIRoutes(t *testing.T) {
+	var spec struct {
+		Paths map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+	_, routes := (&Server{}).mux("")
+	for r := range routes {
+		if !strings.HasPrefix(r, "/api/") || r == "/api/v1/openapi.json" {
+			continue
+		}
+		if _, ok := spec.Paths[r]; !ok {
+			t.Errorf("route %s is not documented", r)
+		}
+	}
+	for p := range spec.Paths {
+		if !routes[p] {
+			t.Errorf("documented path %s is not a route", p)
+		}
+	}
+}
+
+func TestOpenAPIRefs(t *testing.T) {
+	var spec map[string]any
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, c := range v {
+				if ref, ok := c.(string); ok && k == "$ref" {
+					if !resolves(spec, ref) {
+						t.Errorf("unresolved ref %s", ref)
+					}
+				}
+				walk(c)
+			}
+		case []any:
+			for _, c := range v {
+				walk(c)
+			}
+		}
+	}
+	walk(spec)
+}
+
+func resolves(spec map[string]any, ref string) bool {
+	var v any = spec
+	for _, p := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return false
+		}
+		if v, ok = m[p]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	var (
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest("GET", "/api/v1/openapi.json", nil)
+	)
+	(&Server{}).Handler("production", "").ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if !json.Valid(w.Body.Bytes()) {
+		t.Error("expected a json document")
+	}
+}
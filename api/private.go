@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+)
+
+const (
+	visibilityPublic  = "public"
+	visibilityPrivate = "private"
+)
+
+// Reports whether v, which defaults to public, is private.
+func parseVisibility(v string) (bool, error) {
+	switch v {
+	case "", visibilityPublic:
+		return false, nil
+	case visibilityPrivate:
+		return true, nil
+	}
+	return false, badRequest(codeInvalidRequest, "invalid visibility").with(map[string]any{
+		"visibility": v,
+		"allowed":    []string{visibilityPublic, visibilityPrivate},
+	})
+}
+
+// Returns the visibility of a tree that is private or not.
+func visibility(private bool) string {
+	if private {
+		return visibilityPrivate
+	}
+	return visibilityPublic
+}
+
+var errPrivateTree = &apiError{
+	status: http.StatusForbidden,
+	code:   codePrivateTree,
+	msg:    "tree is private",
+}
+
+// Returns a new read token and the hash that is stored
+// in its place. Like API keys, tokens are hashed with
+// sha256 since they have enough entropy not to need a kdf.
+func newReadToken() (string, []byte, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashAPIKey(token), nil
+}
+
+// Returns the read token sent with the request, if any.
+func readToken(r *http.Request) string {
+	return r.Header.Get("X-Read-Token")
+}
+
+// Reports whether the leaves of tr may be listed or
+// searched by address. Private trees are readable
+// with their read token or the API key of their owner.
+func (s *Server) canRead(ctx context.Context, tr getTreeResp, token string) bool {
+	if !tr.private {
+		return true
+	}
+	if token != "" && subtle.ConstantTimeCompare(hashAPIKey(token), tr.readTokenHash) == 1 {
+		return true
+	}
+	k, ok := apiKeyFromContext(ctx)
+	return ok && tr.owner != nil && *tr.owner == k.Name
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/contextwtf/lanyard/merkle"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestPrivateTreeProof(t *testing.T) {
+	token, tokenHash, err := newReadToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		td = getTreeResp{
+			UnhashedLeaves: []hexutil.Bytes{
+				common.FromHex("0x00000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000001"),
+				common.FromHex("0x00000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000002"),
+			},
+			Ltd:           []string{"address", "uint256"},
+			Packed:        true,
+			private:       true,
+			readTokenHash: tokenHash,
+		}
+		root = common.BytesToHash(newCachedTree(td, nil).t.Root())
+		s    = &Server{
+			tlru: newTreeCache(0, 0),
+			loadTree: func(ctx context.Context, r []byte) (getTreeResp, merkle.Tree, error) {
+				return td, nil, nil
+			},
+		}
+	)
+
+	cases := []struct {
+		desc   string
+		query  url.Values
+		token  string
+		status int
+		cache  string
+	}{
+		{
+			desc:   "leaf holder",
+			query:  url.Values{"unhashedLeaf": {td.UnhashedLeaves[1].String()}},
+			status: http.StatusOK,
+			cache:  "public, max-age=31536000",
+		},
+		{
+			desc:   "address without token",
+			query:  url.Values{"address": {"0x0000000000000000000000000000000000000002"}},
+			status: http.StatusForbidden,
+		},
+		{
+			desc:   "address with wrong token",
+			query:  url.Values{"address": {"0x0000000000000000000000000000000000000002"}},
+			token:  "wrong",
+			status: http.StatusForbidden,
+		},
+		{
+			desc:   "address with token",
+			query:  url.Values{"address": {"0x0000000000000000000000000000000000000002"}},
+			token:  token,
+			status: http.StatusOK,
+			cache:  "private, max-age=60",
+		},
+	}
+	for _, c := range cases {
+		c.query.Set("root", root.Hex())
+		var (
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest("GET", "/api/v1/proof?"+c.query.Encode(), nil)
+		)
+		if c.token != "" {
+			r.Header.Set("X-Read-Token", c.token)
+		}
+		s.GetProof(w, r)
+		if w.Code != c.status {
+			t.Errorf("%s: expected %d, got %d %s", c.desc, c.status, w.Code, w.Body)
+		}
+		if c.cache != "" && w.Header().Get("Cache-Control") != c.cache {
+			t.Errorf("%s: expected Cache-Control %q, got %q", c.desc, c.cache, w.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+func TestCanReadOwner(t *testing.T) {
+	var (
+		s     = &Server{}
+		owner = "partner"
+		tr    = getTreeResp{private: true, owner: &owner}
+		ctx   = context.Background()
+	)
+	if s.canRead(ctx, tr, "") {
+		t.Error("expected private tree not to be readable")
+	}
+	if !s.canRead(withAPIKey(ctx, APIKey{Name: owner}), tr, "") {
+		t.Error("expected private tree to be readable by its owner")
+	}
+	if s.canRead(withAPIKey(ctx, APIKey{Name: "other"}), tr, "") {
+		t.Error("expected private tree not to be readable by another key")
+	}
+	if !s.canRead(ctx, getTreeResp{}, "") {
+		t.Error("expected public tree to be readable")
+	}
+}
+
+func TestParseVisibility(t *testing.T) {
+	for v, want := range map[string]bool{"": false, "public": false, "private": true} {
+		got, err := parseVisibility(v)
+		if err != nil || got != want {
+			t.Errorf("%q: expected %t, got %t %v", v, want, got, err)
+		}
+	}
+	if _, err := parseVisibility("secret"); err == nil {
+		t.Error("expected invalid visibility to be rejected")
+	}
+}
@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"sync/atomic"
+	"time"
 
+	"github.com/contextwtf/lanyard/api/tracing"
 	"github.com/contextwtf/lanyard/merkle"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog/log"
 )
 
 type getProofResp struct {
@@ -20,85 +25,294 @@ type getProofResp struct {
 type cachedTree struct {
 	r getTreeResp
 	t merkle.Tree
+
+	// leaves and addrs are built once when the tree
+	// is loaded so that proof lookups don't need to
+	// scan every leaf on each request
+	leaves merkle.LeafIndex
+	addrs  map[string]int
+
+	// when the tree was last checked against the db, in
+	// unix nanoseconds; shared by the copies of the tree
+	checked *atomic.Int64
+}
+
+// Builds the cached tree from td. If t is nil or doesn't have
+// a hash for each leaf in td, the tree is rebuilt from the
+// leaves. Hashing every leaf again to check t would cost about
+// as much as rebuilding it, so t's root is checked by
+// getCachedTree and each proof by proof instead.
+func newCachedTree(td getTreeResp, t merkle.Tree) cachedTree {
+	leaves := make([][]byte, 0, len(td.UnhashedLeaves))
+	for _, l := range td.UnhashedLeaves {
+		leaves = append(leaves, l[:])
+	}
+
+	if len(t) == 0 || len(t[0]) != len(leaves) {
+		t = merkle.NewParallel(leaves)
+	}
+	ct := cachedTree{
+		r:      td,
+		t:      t,
+		leaves: t.LeafIndex(),
+		addrs:  make(map[string]int, len(leaves)),
+
+		checked: new(atomic.Int64),
+	}
+	ct.checked.Store(time.Now().UnixNano())
+	for i, l := range leaves {
+		addr := leaf2Addr(l, td.Ltd, td.Packed)
+		if len(addr) == 0 {
+			continue
+		}
+		if _, ok := ct.addrs[string(addr)]; !ok {
+			ct.addrs[string(addr)] = i
+		}
+	}
+	return ct
+}
+
+// Approximate memory overheads, on 64-bit platforms, of a
+// slice header and of a map entry's bucket slot at the
+// average load factor, excluding its key and value.
+const (
+	sliceOverhead    = 24
+	stringOverhead   = 16
+	intOverhead      = 8
+	mapEntryOverhead = 16
+)
+
+// Returns the approximate number of bytes held by the
+// tree's leaves, levels and lookup maps, including the
+// slice headers and map entries that hold them.
+func (ct cachedTree) size() int64 {
+	var n int64
+	for _, l := range ct.r.UnhashedLeaves {
+		n += sliceOverhead + int64(len(l))
+	}
+	for _, level := range ct.t {
+		n += sliceOverhead
+		for _, h := range level {
+			n += sliceOverhead + int64(len(h))
+		}
+	}
+	n += int64(len(ct.leaves)) * (32 + intOverhead + mapEntryOverhead)
+	for a := range ct.addrs {
+		n += stringOverhead + int64(len(a)) + intOverhead + mapEntryOverhead
+	}
+	return n
 }
 
+// Reports whether the tree has expired at now.
+func (ct cachedTree) expired(now time.Time) bool {
+	return ct.r.ExpiresAt != nil && !now.Before(*ct.r.ExpiresAt)
+}
+
+// Reports whether the tree is due to be checked against
+// the db, and if so marks it as checked at now so that
+// concurrent lookups don't check it again.
+func (ct cachedTree) due(now time.Time, interval time.Duration) bool {
+	if ct.checked == nil || interval <= 0 {
+		return false
+	}
+	last := ct.checked.Load()
+	if now.Sub(time.Unix(0, last)) < interval {
+		return false
+	}
+	return ct.checked.CompareAndSwap(last, now.UnixNano())
+}
+
+// Returns the index of the leaf in the tree. If leaf is
+// empty, the first leaf containing addr is used instead.
+// Returns -1 if no matching leaf is found.
+func (ct cachedTree) index(leaf, addr []byte) int {
+	if len(leaf) > 0 {
+		return ct.leaves.Index(leaf)
+	}
+	if i, ok := ct.addrs[string(addr)]; ok {
+		return i
+	}
+	return -1
+}
+
+// treeBuildTimeout bounds a coalesced tree build, which
+// isn't tied to the lifetime of any single request.
+const treeBuildTimeout = time.Minute
+
+// Returns the tree for root from the cache or else loads and
+// builds it. Concurrent misses for the same root share a single
+// load and build. A caller whose context is done stops waiting
+// and returns the context's error while the build continues
+// for the remaining callers.
 func (s *Server) getCachedTree(ctx context.Context, root common.Hash) (cachedTree, error) {
+	ctx, span := tracing.Start(ctx, "tree.cache.lookup",
+		tracing.WithAttributes(tracing.String("tree.root", root.Hex())),
+	)
+	defer span.End()
+
 	r, ok := s.tlru.Get(root)
+	span.SetAttributes(tracing.Bool("cache.hit", ok))
+	if ok && r.expired(time.Now()) {
+		// the collector may not have deleted it yet
+		s.tlru.Remove(root)
+		return cachedTree{}, pgx.ErrNoRows
+	}
+	if ok && r.due(time.Now(), s.treeCheck) {
+		ok = s.stillCached(ctx, root, r)
+	}
 	if ok {
 		return r, nil
 	}
 
-	td, err := getTree(ctx, s.db, root.Bytes())
-	if err != nil {
-		return cachedTree{}, err
+	bctx := detachedContext{ctx}
+	ch := s.treeBuilds.DoChan(string(root[:]), func() (any, error) {
+		// a build for this root may have completed between the
+		// cache miss above and the start of this build
+		if ct, ok := s.tlru.Peek(root); ok {
+			return ct, nil
+		}
+
+		ctx, cancel := context.WithTimeout(bctx, treeBuildTimeout)
+		defer cancel()
+		ctx, span := tracing.Start(ctx, "tree.build",
+			tracing.WithAttributes(tracing.String("tree.source", buildLoad)),
+		)
+		defer span.End()
+
+		start := time.Now()
+		td, t, err := s.loadTree(ctx, root.Bytes())
+		if err != nil {
+			if !errors.Is(err, pgx.ErrNoRows) {
+				span.RecordError(err)
+			}
+			return cachedTree{}, err
+		}
+
+		ct := newCachedTree(td, t)
+		if t != nil && !bytes.Equal(ct.t.Root(), root[:]) {
+			// persisted levels that are stale or corrupt
+			// mustn't be used to serve proofs
+			log.Ctx(ctx).Warn().Str("root", root.Hex()).Msg("rebuilding tree with invalid levels")
+			ct = newCachedTree(td, nil)
+		}
+		span.SetAttributes(tracing.Int("tree.leaves", len(td.UnhashedLeaves)))
+		s.metrics.observeBuild(buildLoad, len(td.UnhashedLeaves), time.Since(start))
+		s.tlru.Add(root, ct)
+		return ct, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return cachedTree{}, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return cachedTree{}, res.Err
+		}
+		return res.Val.(cachedTree), nil
 	}
+}
 
-	leaves := [][]byte{}
-	for _, l := range td.UnhashedLeaves {
-		leaves = append(leaves, l[:])
+// Checks the cached tree r against the db and evicts it if the
+// tree was deleted, expired or replaced, which another server may
+// have done. Reports whether r can still be served. r is kept
+// if the check fails, so that the db being down doesn't also
+// stop trees being served from the cache.
+func (s *Server) stillCached(ctx context.Context, root common.Hash, r cachedTree) bool {
+	insertedAt, err := s.checkTree(ctx, root.Bytes())
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		log.Ctx(ctx).Warn().Err(err).Str("root", root.Hex()).Msg("checking cached tree")
+		return true
+	case insertedAt.Equal(r.r.insertedAt):
+		return true
 	}
+	s.tlru.Remove(root)
+	return false
+}
 
-	t := merkle.New(leaves)
-	ct := cachedTree{
-		r: td,
-		t: t,
+// Returns the proof of the leaf at idx in ct, the tree with
+// root. A proof that isn't valid, which persisted levels with a
+// corrupt node below the root give, is logged and the tree is
+// rebuilt from its leaves and replaces ct and the cached tree.
+func (s *Server) proof(ctx context.Context, root common.Hash, ct *cachedTree, idx int) [][]byte {
+	p := ct.t.Proof(idx)
+	if merkle.Valid(root[:], p, ct.r.UnhashedLeaves[idx]) {
+		return p
 	}
+	log.Ctx(ctx).Warn().Str("root", root.Hex()).Msg("rebuilding tree with invalid levels")
+	rebuilt, _, _ := s.treeBuilds.Do("rebuild:"+string(root[:]), func() (any, error) {
+		rt := newCachedTree(ct.r, nil)
+		s.tlru.Remove(root)
+		s.tlru.Add(root, rt)
+		return rt, nil
+	})
+	*ct = rebuilt.(cachedTree)
+	return ct.t.Proof(idx)
+}
 
-	s.tlru.Add(root, ct)
-	return ct, nil
+// detachedContext keeps the values of its parent, such as the
+// logger and trace span, but is never canceled so that work shared
+// between requests outlives the request that started it.
+type detachedContext struct {
+	context.Context
 }
 
-func (s *Server) GetProof(w http.ResponseWriter, r *http.Request) {
-	var (
-		ctx  = r.Context()
-		root = common.HexToHash(r.URL.Query().Get("root"))
-		leaf = common.FromHex(r.URL.Query().Get("unhashedLeaf"))
-		addr = common.FromHex(r.URL.Query().Get("address"))
-	)
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
 
-	if len(root) == 0 {
-		s.sendJSONError(r, w, nil, http.StatusBadRequest, "missing root")
-		return
-	}
+// Returns the tree for root and the index of the leaf in
+// the tree. If leaf is empty, the first leaf containing
+// addr is used instead, which requires the read token
+// of a private tree since it reveals the leaf.
+func (s *Server) findLeaf(ctx context.Context, root common.Hash, leaf, addr []byte, token string) (cachedTree, int, error) {
 	if len(leaf) == 0 && len(addr) == 0 {
-		s.sendJSONError(r, w, nil, http.StatusBadRequest, "missing leaf")
-		return
+		return cachedTree{}, 0, badRequest(codeMissingLeaf, "missing leaf")
 	}
 
 	ct, err := s.getCachedTree(ctx, root)
 	if errors.Is(err, pgx.ErrNoRows) {
-		s.sendJSONError(r, w, nil, http.StatusNotFound, "tree not found")
-		w.Header().Set("Cache-Control", "public, max-age=60")
-		return
+		return cachedTree{}, 0, notFound(codeTreeNotFound, "tree not found")
 	} else if err != nil {
-		s.sendJSONError(r, w, err, http.StatusInternalServerError, "selecting proof")
-		return
+		return cachedTree{}, 0, internalError(err, "selecting proof")
 	}
 
+	if len(leaf) == 0 && !s.canRead(ctx, ct.r, token) {
+		return cachedTree{}, 0, errPrivateTree
+	}
+
+	// check if leaf is in tree and error if not
+	idx := ct.index(leaf, addr)
+	if idx == -1 {
+		return cachedTree{}, 0, notFound(codeLeafNotFound, "leaf not found in tree")
+	}
+	return ct, idx, nil
+}
+
+func (s *Server) GetProof(w http.ResponseWriter, r *http.Request) {
 	var (
-		target []byte
+		ctx  = r.Context()
+		root = common.HexToHash(r.URL.Query().Get("root"))
+		leaf = common.FromHex(r.URL.Query().Get("unhashedLeaf"))
+		addr = common.FromHex(r.URL.Query().Get("address"))
 	)
-	// check if leaf is in tree and error if not
-	for _, l := range ct.r.UnhashedLeaves {
-		if len(target) == 0 {
-			if len(leaf) > 0 {
-				if bytes.Equal(l, leaf) {
-					target = l
-				}
-			} else if bytes.Equal(leaf2Addr(l, ct.r.Ltd, ct.r.Packed), addr) {
-				target = l
-			}
-		}
+
+	if len(root) == 0 {
+		s.sendJSONError(r, w, badRequest(codeMissingRoot, "missing root"))
+		return
 	}
 
-	if len(target) == 0 {
-		s.sendJSONError(r, w, nil, http.StatusNotFound, "leaf not found in tree")
+	ct, idx, err := s.findLeaf(ctx, root, leaf, addr, readToken(r))
+	if err != nil {
+		s.sendJSONError(r, w, err)
 		return
 	}
 
 	var (
-		p    = ct.t.Proof(ct.t.Index(target))
-		phex = []hexutil.Bytes{}
+		target = ct.r.UnhashedLeaves[idx]
+		p      = s.proof(ctx, root, &ct, idx)
+		phex   = []hexutil.Bytes{}
 	)
 
 	// convert [][]byte to []hexutil.Bytes
@@ -108,12 +322,29 @@ func (s *Server) GetProof(w http.ResponseWriter, r *http.Request) {
 
 	// cache for 1 year if we're returning an unhashed leaf proof
 	// or 60 seconds for an address proof
+	// or privately when the read token was required
+	// and never past the tree's expiry
+	now := time.Now()
+	switch {
+	case len(leaf) > 0:
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", capMaxAge(31536000, ct.r.ExpiresAt, now)))
+	case ct.r.private:
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", capMaxAge(60, ct.r.ExpiresAt, now)))
+	default:
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", capMaxAge(60, ct.r.ExpiresAt, now)))
+	}
+	var (
+		contentType = []byte(responseContentType(r))
+		tag         = etag("proof.address", root[:], contentType, addr)
+	)
 	if len(leaf) > 0 {
-		w.Header().Set("Cache-Control", "public, max-age=31536000")
-	} else {
-		w.Header().Set("Cache-Control", "public, max-age=60")
+		tag = etag("proof.leaf", root[:], contentType, leaf)
+	}
+	w.Header().Add("Vary", "Accept")
+	if notModified(w, r, tag, ct.r.insertedAt) {
+		return
 	}
-	s.sendJSON(r, w, getProofResp{
+	s.sendEncoded(r, w, getProofResp{
 		UnhashedLeaf: target,
 		Proof:        phex,
 	})
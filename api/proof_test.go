@@ -0,0 +1,338 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/contextwtf/lanyard/merkle"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestCachedTreeIndex(t *testing.T) {
+	ct := newCachedTree(getTreeResp{
+		UnhashedLeaves: []hexutil.Bytes{
+			common.FromHex("0x00000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000001"),
+			common.FromHex("0x00000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000002"),
+			common.FromHex("0x00000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000003"),
+		},
+		Ltd:    []string{"address", "uint256"},
+		Packed: true,
+	}, nil)
+
+	cases := []struct {
+		leaf []byte
+		addr []byte
+		want int
+	}{
+		{
+			leaf: ct.r.UnhashedLeaves[1],
+			want: 1,
+		},
+		{
+			leaf: ct.r.UnhashedLeaves[2],
+			want: 2,
+		},
+		{
+			leaf: common.FromHex("0x01"),
+			want: -1,
+		},
+		{
+			addr: common.FromHex("0x0000000000000000000000000000000000000001"),
+			want: 0,
+		},
+		{
+			addr: common.FromHex("0x0000000000000000000000000000000000000002"),
+			want: 1,
+		},
+		{
+			addr: common.FromHex("0x0000000000000000000000000000000000000003"),
+			want: -1,
+		},
+	}
+
+	for _, c := range cases {
+		got := ct.index(c.leaf, c.addr)
+		if got != c.want {
+			t.Errorf("expected: %d got: %d", c.want, got)
+		}
+	}
+}
+
+func TestGetCachedTreeCoalesces(t *testing.T) {
+	var (
+		reads   atomic.Int32
+		entered = make(chan struct{})
+		release = make(chan struct{})
+		s       = &Server{
+			tlru: newTreeCache(0, 0),
+			loadTree: func(ctx context.Context, root []byte) (getTreeResp, merkle.Tree, error) {
+				if reads.Add(1) == 1 {
+					close(entered)
+				}
+				<-release
+				return getTreeResp{UnhashedLeaves: []hexutil.Bytes{{1}, {2}}}, nil, nil
+			},
+		}
+		wg sync.WaitGroup
+	)
+
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ct, err := s.getCachedTree(context.Background(), common.Hash{1})
+			if err != nil {
+				t.Error(err)
+			} else if len(ct.r.UnhashedLeaves) != 2 {
+				t.Errorf("expected 2 leaves, got %d", len(ct.r.UnhashedLeaves))
+			}
+		}()
+	}
+	// callers that miss the cache after the build has started
+	// join it, and those after it has finished hit the cache
+	<-entered
+	close(release)
+	wg.Wait()
+
+	if n := reads.Load(); n != 1 {
+		t.Errorf("expected 1 db read, got %d", n)
+	}
+}
+
+func TestGetCachedTreeCanceled(t *testing.T) {
+	var (
+		reads   atomic.Int32
+		entered = make(chan struct{})
+		release = make(chan struct{})
+		s       = &Server{
+			tlru: newTreeCache(0, 0),
+			loadTree: func(ctx context.Context, root []byte) (getTreeResp, merkle.Tree, error) {
+				reads.Add(1)
+				close(entered)
+				<-release
+				if ctx.Err() != nil {
+					return getTreeResp{}, nil, ctx.Err()
+				}
+				return getTreeResp{UnhashedLeaves: []hexutil.Bytes{{1}, {2}}}, nil, nil
+			},
+		}
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+
+	errc := make(chan error)
+	go func() {
+		_, err := s.getCachedTree(ctx, common.Hash{1})
+		errc <- err
+	}()
+	<-entered
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// the abandoned build should still complete and be cached,
+	// so a later caller either joins it or hits the cache
+	close(release)
+	if _, err := s.getCachedTree(context.Background(), common.Hash{1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.tlru.Peek(common.Hash{1}); !ok {
+		t.Error("expected abandoned build to be cached")
+	}
+	if n := reads.Load(); n != 1 {
+		t.Errorf("expected 1 db read, got %d", n)
+	}
+}
+
+func TestGetCachedTreeInvalidLevels(t *testing.T) {
+	var (
+		td    = getTreeResp{UnhashedLeaves: []hexutil.Bytes{{1}, {2}, {3}}}
+		valid = merkle.New([][]byte{{1}, {2}, {3}})
+		root  = common.BytesToHash(valid.Root())
+	)
+	tampered := merkle.New([][]byte{{1}, {2}, {3}})
+	tampered[len(tampered)-1][0] = bytes.Repeat([]byte{9}, 32)
+
+	cases := []struct {
+		desc   string
+		levels merkle.Tree
+	}{
+		{"levels of other leaves", merkle.New([][]byte{{4}, {5}, {6}})},
+		{"tampered root", tampered},
+		{"truncated", valid[:1]},
+	}
+	for _, c := range cases {
+		s := &Server{
+			tlru: newTreeCache(0, 0),
+			loadTree: func(ctx context.Context, root []byte) (getTreeResp, merkle.Tree, error) {
+				return td, c.levels, nil
+			},
+		}
+		ct, err := s.getCachedTree(context.Background(), root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(ct.t.Root(), root[:]) {
+			t.Errorf("%s: expected the tree to be rebuilt", c.desc)
+		}
+		p := ct.t.Proof(1)
+		if !merkle.Valid(root[:], p, []byte{2}) {
+			t.Errorf("%s: expected a valid proof", c.desc)
+		}
+	}
+}
+
+func TestProofInvalidLevels(t *testing.T) {
+	var (
+		leaves = [][]byte{{1}, {2}, {3}, {4}, {5}}
+		td     = getTreeResp{UnhashedLeaves: []hexutil.Bytes{{1}, {2}, {3}, {4}, {5}}}
+		root   = common.BytesToHash(merkle.New(leaves).Root())
+	)
+	cases := []struct {
+		desc   string
+		tamper func(merkle.Tree)
+	}{
+		{"valid", func(merkle.Tree) {}},
+		{"tampered middle level", func(t merkle.Tree) { t[1][0] = bytes.Repeat([]byte{9}, 32) }},
+		{"tampered leaf hash", func(t merkle.Tree) { t[0][3] = bytes.Repeat([]byte{9}, 32) }},
+	}
+	for _, c := range cases {
+		levels := merkle.New(leaves)
+		c.tamper(levels)
+		s := &Server{
+			tlru: newTreeCache(0, 0),
+			loadTree: func(ctx context.Context, root []byte) (getTreeResp, merkle.Tree, error) {
+				return td, levels, nil
+			},
+		}
+		ctx := context.Background()
+		ct, err := s.getCachedTree(ctx, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the persisted levels are used without hashing the leaves
+		if &ct.t[0][0][0] != &levels[0][0][0] {
+			t.Fatalf("%s: expected the persisted levels to be used", c.desc)
+		}
+
+		p := s.proof(ctx, root, &ct, 2)
+		if !merkle.Valid(root[:], p, leaves[2]) {
+			t.Errorf("%s: expected a valid proof", c.desc)
+		}
+		cached, _ := s.tlru.Peek(root)
+		if !merkle.Valid(root[:], cached.t.Proof(2), leaves[2]) {
+			t.Errorf("%s: expected the cached tree to be rebuilt", c.desc)
+		}
+	}
+}
+
+func benchTree(n int) getTreeResp {
+	td := getTreeResp{
+		Ltd:    []string{"address", "uint256"},
+		Packed: true,
+	}
+	for i := 0; i < n; i++ {
+		l := make([]byte, 52)
+		l[16], l[17], l[18], l[19] = byte(i>>24), byte(i>>16), byte(i>>8), byte(i)
+		td.UnhashedLeaves = append(td.UnhashedLeaves, l)
+	}
+	return td
+}
+
+// BenchmarkAddrScan measures a linear scan of the leaves,
+// which is how address lookups worked before cachedTree
+// carried an index.
+func BenchmarkAddrScan(b *testing.B) {
+	var (
+		td   = benchTree(100000)
+		addr = leaf2Addr(td.UnhashedLeaves[len(td.UnhashedLeaves)-1], td.Ltd, td.Packed)
+	)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, l := range td.UnhashedLeaves {
+			if bytes.Equal(leaf2Addr(l, td.Ltd, td.Packed), addr) {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkAddrIndex(b *testing.B) {
+	var (
+		ct   = newCachedTree(benchTree(100000), nil)
+		addr = leaf2Addr(ct.r.UnhashedLeaves[len(ct.r.UnhashedLeaves)-1], ct.r.Ltd, ct.r.Packed)
+	)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ct.index(nil, addr)
+	}
+}
+
+func TestGetCachedTreeCheck(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		root     = common.Hash{1}
+		inserted = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+		reads    int
+		checks   int
+		checkErr error
+		checkAt  = inserted
+		s        = &Server{
+			tlru:      newTreeCache(0, 0),
+			treeCheck: time.Minute,
+			loadTree: func(ctx context.Context, root []byte) (getTreeResp, merkle.Tree, error) {
+				reads++
+				return getTreeResp{UnhashedLeaves: []hexutil.Bytes{{1}, {2}}, insertedAt: inserted}, nil, nil
+			},
+			checkTree: func(ctx context.Context, root []byte) (time.Time, error) {
+				checks++
+				return checkAt, checkErr
+			},
+		}
+	)
+	get := func() cachedTree {
+		t.Helper()
+		ct, err := s.getCachedTree(ctx, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ct
+	}
+	stale := func(ct cachedTree) {
+		ct.checked.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+	}
+
+	ct := get()
+	get()
+	if reads != 1 || checks != 0 {
+		t.Fatalf("expected 1 read and no checks, got %d and %d", reads, checks)
+	}
+
+	// unchanged, and a failed check, keep the tree
+	stale(ct)
+	get()
+	checkErr = errors.New("db down")
+	stale(ct)
+	get()
+	if reads != 1 || checks != 2 {
+		t.Fatalf("expected 1 read and 2 checks, got %d and %d", reads, checks)
+	}
+
+	// deleted, or deleted and created again
+	checkErr = pgx.ErrNoRows
+	stale(ct)
+	ct = get()
+	checkErr, checkAt = nil, inserted.Add(time.Hour)
+	stale(ct)
+	get()
+	if reads != 3 || checks != 4 {
+		t.Fatalf("expected 3 reads and 4 checks, got %d and %d", reads, checks)
+	}
+}
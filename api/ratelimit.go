@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// A RateLimit is a token bucket that holds up to Burst
+// requests and refills at Rate requests per second.
+// A zero Rate disables the limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute returns a limit of n requests per minute
+// that allows bursts of up to burst requests.
+func PerMinute(n float64, burst int) RateLimit {
+	return RateLimit{Rate: n / 60, Burst: burst}
+}
+
+func (l RateLimit) disabled() bool {
+	return l.Rate <= 0
+}
+
+// Returns the burst, which is at least one request.
+func (l RateLimit) burst() float64 {
+	if l.Burst < 1 {
+		return 1
+	}
+	return float64(l.Burst)
+}
+
+// A RateLimiter stores the token buckets of every key.
+// Allow takes a token from the bucket for key and
+// reports how long to wait when the bucket is empty.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, l RateLimit) (ok bool, retryAfter time.Duration, err error)
+}
+
+// WithRateLimits limits the requests of each API key, or of
+// each client IP for requests without a valid key. Creating and
+// deleting trees uses the writes budget and every other /api
+// request uses the reads budget. Buckets are held in memory
+// unless a shared limiter is set with [WithRateLimiter].
+func WithRateLimits(writes, reads RateLimit) Option {
+	return func(s *Server) {
+		s.writeLimit = writes
+		s.readLimit = reads
+	}
+}
+
+// WithRateLimiter stores the rate limit buckets in l,
+// such as a [RedisRateLimiter] shared between servers.
+func WithRateLimiter(l RateLimiter) Option {
+	return func(s *Server) {
+		s.limiter = l
+	}
+}
+
+// How long to wait for a bucket to be full for the given
+// number of missing tokens.
+func refillTime(missing float64, l RateLimit) time.Duration {
+	return time.Duration(math.Ceil(missing / l.Rate * float64(time.Second)))
+}
+
+// memoryLimiter holds token buckets in memory, which limits
+// each server separately.
+type memoryLimiter struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens float64
+	at     time.Time
+	full   time.Time
+}
+
+func newMemoryLimiter() *memoryLimiter {
+	return &memoryLimiter{
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}
+}
+
+// bucketSweepInterval is how often full buckets are
+// dropped, since they're the same as a missing bucket.
+const bucketSweepInterval = time.Minute
+
+func (m *memoryLimiter) Allow(ctx context.Context, key string, l RateLimit) (bool, time.Duration, error) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.swept) > bucketSweepInterval {
+		for k, b := range m.buckets {
+			if !now.Before(b.full) {
+				delete(m.buckets, k)
+			}
+		}
+		m.swept = now
+	}
+
+	burst := l.burst()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, at: now}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.at).Seconds()*l.Rate)
+	b.at = now
+
+	if b.tokens < 1 {
+		return false, refillTime(1-b.tokens, l), nil
+	}
+	b.tokens--
+	b.full = now.Add(refillTime(burst-b.tokens, l))
+	return true, 0, nil
+}
+
+func errRateLimited(retryAfter time.Duration) *apiError {
+	return &apiError{
+		status: http.StatusTooManyRequests,
+		code:   codeRateLimited,
+		msg:    "rate limit exceeded",
+		details: map[string]any{
+			"retryAfter": retryAfterSeconds(retryAfter),
+		},
+	}
+}
+
+// Rounds up so that clients don't retry too early.
+func retryAfterSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// Returns the bucket key for the request's API key,
+// or for ip if the request doesn't have one.
+func rateLimitKey(ctx context.Context, ip string) string {
+	if k, ok := apiKeyFromContext(ctx); ok {
+		return "key:" + k.Name
+	}
+	return "ip:" + ip
+}
+
+// Takes a token from the write or read budget of the
+// request's key. Limiter errors are logged and the
+// request is allowed so that an unavailable shared
+// limiter doesn't take down the api.
+func (s *Server) rateLimit(ctx context.Context, ip string, write bool) error {
+	l := s.readLimit
+	if write {
+		l = s.writeLimit
+	}
+	if l.disabled() {
+		return nil
+	}
+	key := rateLimitKey(ctx, ip)
+	if write {
+		key = "write:" + key
+	} else {
+		key = "read:" + key
+	}
+
+	ok, retryAfter, err := s.limiter.Allow(ctx, key, l)
+	if err != nil {
+		log.Ctx(ctx).Err(err).Msg("rate limiter")
+		return nil
+	}
+	if !ok {
+		return errRateLimited(retryAfter)
+	}
+	return nil
+}
+
+// Reports whether the request uses the writes budget.
+func isWrite(r *http.Request) bool {
+	return r.URL.Path == "/api/v1/tree" && r.Method != http.MethodGet
+}
+
+// Takes a token from the budget of the client IP for a request
+// that failed authentication, so that guessing keys is limited
+// like requests without a key. Returns the rate limit error if
+// the IP's budget is spent, or err.
+func (s *Server) rateLimitFailedAuth(ctx context.Context, ip string, write bool, err error) error {
+	var ae *apiError
+	if !errors.As(err, &ae) || ae.status != http.StatusUnauthorized {
+		return err
+	}
+	// ctx has no key, so the IP's bucket is used
+	if lerr := s.rateLimit(ctx, ip, write); lerr != nil {
+		return lerr
+	}
+	return err
+}
+
+// rateLimitHandler limits requests to /api. It runs after
+// authHandler so that requests with a valid key use the
+// key's budget; requests with an invalid key use the
+// IP's budget in authHandler.
+func (s *Server) rateLimitHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if err := s.rateLimit(r.Context(), s.proxies.ClientIP(r), isWrite(r)); err != nil {
+			s.sendJSONError(r, w, err)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Returns the client IP of a grpc request. It is read from the
+// metadata of trusted proxies like the http headers.
+func (s *Server) grpcClientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	var (
+		md, _ = metadata.FromIncomingContext(ctx)
+		h     = http.Header{}
+	)
+	for _, name := range s.proxies.Headers {
+		for _, v := range md.Get(name) {
+			h.Add(name, v)
+		}
+	}
+	return s.proxies.clientIP(p.Addr.String(), h)
+}
+
+// Reports whether the grpc method uses the writes budget.
+func isGRPCWrite(info *grpc.UnaryServerInfo) bool {
+	return strings.HasSuffix(info.FullMethod, "/CreateTree") ||
+		strings.HasSuffix(info.FullMethod, "/DeleteTree")
+}
+
+// The grpc equivalent of rateLimitHandler, which runs
+// after authInterceptor.
+func (s *Server) rateLimitInterceptor(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	if err := s.rateLimit(ctx, s.grpcClientIP(ctx), isGRPCWrite(info)); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return handler(ctx, req)
+}
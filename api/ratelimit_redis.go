@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisRateLimiter holds token buckets in Redis, or a server
+// compatible with its scripting commands, so that the rate
+// limits apply across every server sharing it.
+type RedisRateLimiter struct {
+	rdb    redis.Scripter
+	prefix string
+}
+
+// NewRedisRateLimiter stores buckets under keys starting
+// with prefix. rdb may be a client, ring or cluster.
+func NewRedisRateLimiter(rdb redis.Scripter, prefix string) *RedisRateLimiter {
+	return &RedisRateLimiter{rdb: rdb, prefix: prefix}
+}
+
+// Takes a token from the bucket in KEYS[1], which holds the
+// tokens and the time in milliseconds they were counted. The
+// bucket expires once it would be full. Returns 1 and 0 when
+// a token was taken, or 0 and the milliseconds until the
+// next token otherwise.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local b = redis.call("HMGET", KEYS[1], "tokens", "at")
+local tokens = tonumber(b[1]) or burst
+local at = tonumber(b[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - at) / 1000 * rate)
+if tokens < 1 then
+	return {0, math.ceil((1 - tokens) / rate * 1000)}
+end
+
+tokens = tokens - 1
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "at", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate * 1000))
+return {1, 0}
+`)
+
+func (rl *RedisRateLimiter) Allow(ctx context.Context, key string, l RateLimit) (bool, time.Duration, error) {
+	res, err := tokenBucketScript.Run(ctx, rl.rdb,
+		[]string{rl.prefix + key},
+		strconv.FormatFloat(l.Rate, 'f', -1, 64),
+		int(l.burst()),
+		time.Now().UnixMilli(),
+	).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
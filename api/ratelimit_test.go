@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	var (
+		ctx = context.Background()
+		now = time.Now()
+		m   = newMemoryLimiter()
+		l   = RateLimit{Rate: 1, Burst: 2}
+	)
+	m.now = func() time.Time { return now }
+
+	steps := []struct {
+		advance    time.Duration
+		ok         bool
+		retryAfter time.Duration
+	}{
+		{ok: true},
+		{ok: true},
+		{ok: false, retryAfter: time.Second},
+		{advance: 500 * time.Millisecond, ok: false, retryAfter: 500 * time.Millisecond},
+		{advance: 500 * time.Millisecond, ok: true},
+		{advance: time.Hour, ok: true},
+		{ok: true},
+		{ok: false, retryAfter: time.Second},
+	}
+	for i, s := range steps {
+		now = now.Add(s.advance)
+		ok, retryAfter, err := m.Allow(ctx, "a", l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != s.ok || retryAfter != s.retryAfter {
+			t.Errorf("step %d: expected %t %s, got %t %s", i, s.ok, s.retryAfter, ok, retryAfter)
+		}
+	}
+
+	if ok, _, _ := m.Allow(ctx, "b", l); !ok {
+		t.Error("expected a separate bucket for b")
+	}
+
+	now = now.Add(time.Hour)
+	m.Allow(ctx, "c", l)
+	if len(m.buckets) != 1 {
+		t.Errorf("expected full buckets to be swept, got %d", len(m.buckets))
+	}
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	s := testKeyServer(
+		WithRateLimits(PerMinute(1, 1), PerMinute(1, 2)),
+		WithRateLimiter(newMemoryLimiter()),
+	)
+	h := s.Handler("production", "")
+
+	send := func(method, path, ip, key string) *httptest.ResponseRecorder {
+		var (
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest(method, path, nil)
+		)
+		r.RemoteAddr = ip + ":1234"
+		if key != "" {
+			r.Header.Set("X-Api-Key", key)
+		}
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	cases := []struct {
+		desc   string
+		method string
+		path   string
+		ip     string
+		key    string
+		status int
+	}{
+		{"read", "GET", "/api/v1/openapi.json", "192.0.2.1", "", http.StatusOK},
+		{"read", "GET", "/api/v1/openapi.json", "192.0.2.1", "", http.StatusOK},
+		{"reads exhausted", "GET", "/api/v1/openapi.json", "192.0.2.1", "", http.StatusTooManyRequests},
+		{"another ip", "GET", "/api/v1/openapi.json", "192.0.2.2", "", http.StatusOK},
+		{"health isn't limited", "GET", "/health", "192.0.2.1", "", http.StatusOK},
+		{"write", "POST", "/api/v1/tree", "192.0.2.1", "", http.StatusBadRequest},
+		{"writes exhausted", "POST", "/api/v1/tree", "192.0.2.1", "", http.StatusTooManyRequests},
+		{"reads of another ip", "GET", "/api/v1/openapi.json", "192.0.2.2", "", http.StatusOK},
+	}
+	for _, c := range cases {
+		w := send(c.method, c.path, c.ip, c.key)
+		if w.Code != c.status {
+			t.Errorf("%s: expected %d, got %d", c.desc, c.status, w.Code)
+			continue
+		}
+		if w.Code != http.StatusTooManyRequests {
+			continue
+		}
+		if w.Header().Get("Retry-After") != "60" {
+			t.Errorf("%s: expected Retry-After 60, got %q", c.desc, w.Header().Get("Retry-After"))
+		}
+		var resp errorResp
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Code != codeRateLimited {
+			t.Errorf("%s: expected %s, got %s", c.desc, codeRateLimited, resp.Code)
+		}
+	}
+}
+
+func TestRateLimitByKey(t *testing.T) {
+	s := testKeyServer(
+		WithAPIKeys(),
+		WithRateLimits(PerMinute(1, 1), RateLimit{}),
+		WithRateLimiter(newMemoryLimiter()),
+	)
+	h := s.Handler("production", "")
+
+	statuses := []int{}
+	for _, key := range []string{"writer", "writer", "reader"} {
+		var (
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest("POST", "/api/v1/tree", nil)
+		)
+		r.Header.Set("X-Api-Key", key)
+		h.ServeHTTP(w, r)
+		statuses = append(statuses, w.Code)
+	}
+
+	// every request is from the same ip, but each key has its
+	// own budget and reader is then rejected for its scope
+	want := []int{http.StatusBadRequest, http.StatusTooManyRequests, http.StatusForbidden}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, statuses)
+			break
+		}
+	}
+}
+
+func TestRateLimitFailedAuth(t *testing.T) {
+	s := testKeyServer(
+		WithRateLimits(RateLimit{}, PerMinute(1, 2)),
+		WithRateLimiter(newMemoryLimiter()),
+	)
+	h := s.Handler("production", "")
+
+	statuses := []int{}
+	for _, key := range []string{"guess", "guess", "guess", "reader"} {
+		var (
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest("GET", "/api/v1/limits", nil)
+		)
+		r.Header.Set("X-Api-Key", key)
+		h.ServeHTTP(w, r)
+		statuses = append(statuses, w.Code)
+	}
+
+	// invalid keys spend the ip's budget, valid keys their own
+	want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusOK}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, statuses)
+			break
+		}
+	}
+
+	// the same ip over grpc
+	var (
+		info = &grpc.UnaryServerInfo{FullMethod: "/lanyard.Lanyard/GetTree"}
+		addr = &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234}
+		ctx  = peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+	)
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-api-key", "guess"))
+	_, err := s.authInterceptor(ctx, nil, info, func(context.Context, any) (any, error) {
+		return nil, nil
+	})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected %v, got %v", codes.ResourceExhausted, err)
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -8,16 +9,16 @@ import (
 	"github.com/jackc/pgx/v4"
 )
 
-func (s *Server) GetRoot(w http.ResponseWriter, r *http.Request) {
-	type rootResp struct {
-		Root hexutil.Bytes `json:"root"`
-		Note string        `json:"note"`
-	}
+type rootResp struct {
+	Root hexutil.Bytes `json:"root"`
+	Note string        `json:"note"`
+}
 
-	type rootsResp struct {
-		Roots []hexutil.Bytes `json:"roots"`
-	}
+type rootsResp struct {
+	Roots []hexutil.Bytes `json:"roots"`
+}
 
+func (s *Server) GetRoot(w http.ResponseWriter, r *http.Request) {
 	var (
 		ctx   = r.Context()
 		err   error
@@ -36,33 +37,13 @@ func (s *Server) GetRoot(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if len(pb) == 0 || err != nil {
-		s.sendJSONError(r, w, nil, http.StatusBadRequest, "missing or malformed list of proofs")
+		s.sendJSONError(r, w, badRequest(codeInvalidProof, "missing or malformed list of proofs"))
 		return
 	}
 
-	const q = `
-		SELECT root
-		FROM proofs_hashes
-		WHERE hash = $1
-		group by 1;
-	`
-	var (
-		roots []hexutil.Bytes
-		rb    hexutil.Bytes
-		ph    = hashProof(pb)
-	)
-
-	_, err = s.db.QueryFunc(ctx, q, []interface{}{&ph}, []interface{}{&rb}, func(qfr pgx.QueryFuncRow) error {
-		roots = append(roots, rb)
-		return nil
-	})
-
+	roots, err := s.lookupRoots(ctx, pb)
 	if err != nil {
-		s.sendJSONError(r, w, err, http.StatusInternalServerError, "selecting root")
-		return
-	} else if len(roots) == 0 { // db.QueryFunc doesn't return pgx.ErrNoRows
-		w.Header().Set("Cache-Control", "public, max-age=60")
-		s.sendJSONError(r, w, nil, http.StatusNotFound, "root not found for proofs")
+		s.sendJSONError(r, w, err)
 		return
 	}
 
@@ -79,3 +60,33 @@ func (s *Server) GetRoot(w http.ResponseWriter, r *http.Request) {
 		s.sendJSON(r, w, rootResp{Root: roots[0], Note: note})
 	}
 }
+
+// Returns the roots of every unexpired tree containing proof.
+func (s *Server) lookupRoots(ctx context.Context, proof [][]byte) ([]hexutil.Bytes, error) {
+	const q = `
+		-- name: LookupRoots
+		SELECT ph.root
+		FROM proofs_hashes ph
+		JOIN trees t ON t.root = ph.root
+		WHERE ph.hash = $1
+		AND (t.expires_at IS NULL OR t.expires_at > now())
+		group by 1;
+	`
+	var (
+		roots []hexutil.Bytes
+		rb    hexutil.Bytes
+		ph    = hashProof(proof)
+	)
+
+	_, err := s.db.QueryFunc(ctx, q, []interface{}{&ph}, []interface{}{&rb}, func(qfr pgx.QueryFuncRow) error {
+		roots = append(roots, rb)
+		return nil
+	})
+
+	if err != nil {
+		return nil, internalError(err, "selecting root")
+	} else if len(roots) == 0 { // db.QueryFunc doesn't return pgx.ErrNoRows
+		return nil, notFound(codeRootNotFound, "root not found for proofs")
+	}
+	return roots, nil
+}
@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+type datadog struct{}
+
+// NewDatadog starts the Datadog tracer, which sends
+// spans to the Datadog agent.
+func NewDatadog(opts ...tracer.StartOption) Tracer {
+	tracer.Start(opts...)
+	return datadog{}
+}
+
+type remoteKey struct{}
+
+func (datadog) Extract(ctx context.Context, h http.Header) context.Context {
+	sc, err := tracer.Extract(tracer.HTTPHeadersCarrier(h))
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+func (datadog) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, Span) {
+	c := newStartConfig(opts)
+	var ddopts []ddtrace.StartSpanOption
+	if parent, ok := tracer.SpanFromContext(ctx); ok {
+		ddopts = append(ddopts, tracer.ChildOf(parent.Context()))
+	} else if remote, ok := ctx.Value(remoteKey{}).(ddtrace.SpanContext); ok {
+		ddopts = append(ddopts, tracer.ChildOf(remote))
+	}
+	if c.kind == KindServer {
+		ddopts = append(ddopts, tracer.SpanType(ext.SpanTypeWeb))
+	}
+	span := tracer.StartSpan(name, ddopts...)
+	s := ddSpan{span}
+	s.SetAttributes(c.attrs...)
+	return tracer.ContextWithSpan(ctx, span), s
+}
+
+func (datadog) Shutdown(ctx context.Context) error {
+	tracer.Stop()
+	return nil
+}
+
+type ddSpan struct {
+	span ddtrace.Span
+}
+
+// Datadog names spans by their resource, so routes
+// and statements are also set as the resource.
+func (s ddSpan) SetAttributes(attrs ...Attr) {
+	for _, a := range attrs {
+		s.span.SetTag(a.Key, a.Value)
+		switch a.Key {
+		case "http.route":
+			s.span.SetTag(ext.ResourceName, a.Value)
+		case "db.statement":
+			s.span.SetTag(ext.ResourceName, a.Value)
+			s.span.SetTag(ext.SpanType, ext.SpanTypeSQL)
+		}
+	}
+}
+
+func (s ddSpan) RecordError(err error) {
+	if err != nil {
+		s.span.SetTag(ext.Error, err)
+	}
+}
+
+func (s ddSpan) TraceID() string {
+	return strconv.FormatUint(s.span.Context().TraceID(), 10)
+}
+
+func (s ddSpan) End() { s.span.Finish() }
@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type otelTracer struct {
+	tp     trace.TracerProvider
+	tracer trace.Tracer
+	prop   propagation.TextMapPropagator
+}
+
+// NewOTel traces with tp and reads the parent span
+// from W3C trace context headers.
+func NewOTel(tp trace.TracerProvider) Tracer {
+	return otelTracer{
+		tp:     tp,
+		tracer: tp.Tracer("github.com/contextwtf/lanyard"),
+		prop:   propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+}
+
+// NewOTLP exports spans to an OpenTelemetry collector using
+// protocol, which is "grpc" or "http/protobuf". The
+// endpoint, headers, sampler and resource are configured by
+// the standard OTEL_ environment variables.
+func NewOTLP(ctx context.Context, protocol, version string) (Tracer, error) {
+	var client otlptrace.Client
+	switch protocol {
+	case "grpc":
+		client = otlptracegrpc.NewClient()
+	case "", "http/protobuf":
+		client = otlptracehttp.NewClient()
+	default:
+		return nil, fmt.Errorf("unknown otlp protocol %q", protocol)
+	}
+	exp, err := otlptrace.New(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("creating otlp exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.version", version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating otel resource: %w", err)
+	}
+	return NewOTel(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+	)), nil
+}
+
+func (t otelTracer) Extract(ctx context.Context, h http.Header) context.Context {
+	return t.prop.Extract(ctx, propagation.HeaderCarrier(h))
+}
+
+func (t otelTracer) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, Span) {
+	c := newStartConfig(opts)
+	topts := []trace.SpanStartOption{
+		trace.WithAttributes(otelAttrs(c.attrs)...),
+	}
+	switch c.kind {
+	case KindServer:
+		topts = append(topts, trace.WithSpanKind(trace.SpanKindServer))
+	case KindClient:
+		topts = append(topts, trace.WithSpanKind(trace.SpanKindClient))
+	}
+	ctx, span := t.tracer.Start(ctx, name, topts...)
+	return ctx, otelSpan{span}
+}
+
+func (t otelTracer) Shutdown(ctx context.Context) error {
+	if tp, ok := t.tp.(interface{ Shutdown(context.Context) error }); ok {
+		return tp.Shutdown(ctx)
+	}
+	return nil
+}
+
+func otelAttrs(attrs []Attr) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(a.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(a.Key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(a.Key, v))
+		case float64:
+			kvs = append(kvs, attribute.Float64(a.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(a.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(a.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) SetAttributes(attrs ...Attr) {
+	s.span.SetAttributes(otelAttrs(attrs)...)
+}
+
+func (s otelSpan) RecordError(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+func (s otelSpan) TraceID() string {
+	sc := s.span.SpanContext()
+	if !sc.IsValid() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+func (s otelSpan) End() { s.span.End() }
@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestOTelPropagation(t *testing.T) {
+	var (
+		rec = tracetest.NewSpanRecorder()
+		tr  = NewOTel(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+		h   = http.Header{}
+	)
+	h.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx := tr.Extract(context.Background(), h)
+	ctx, parent := tr.Start(ctx, "parent", WithKind(KindServer))
+	_, child := tr.Start(ctx, "child", WithAttributes(Int("n", 2)))
+	child.RecordError(errors.New("failed"))
+	child.End()
+	parent.End()
+
+	if got := parent.TraceID(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the propagated trace id, got %q", got)
+	}
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	c, p := spans[0], spans[1]
+	if p.Parent().SpanID().String() != "00f067aa0ba902b7" || !p.Parent().IsRemote() {
+		t.Errorf("expected the remote parent, got %s", p.Parent().SpanID())
+	}
+	if p.SpanKind() != trace.SpanKindServer {
+		t.Errorf("expected a server span, got %s", p.SpanKind())
+	}
+	if c.Parent().SpanID() != p.SpanContext().SpanID() {
+		t.Errorf("expected child of %s, got %s", p.SpanContext().SpanID(), c.Parent().SpanID())
+	}
+	if c.Status().Code != codes.Error {
+		t.Errorf("expected an error status, got %v", c.Status())
+	}
+	if len(c.Attributes()) != 1 || c.Attributes()[0].Value.AsInt64() != 2 {
+		t.Errorf("unexpected attributes %v", c.Attributes())
+	}
+}
+
+func TestNoopTracer(t *testing.T) {
+	ctx := context.Background()
+	if got := Extract(ctx, http.Header{"Traceparent": {"x"}}); got != ctx {
+		t.Error("expected the context to be unchanged")
+	}
+	_, span := Start(ctx, "noop")
+	if span.TraceID() != "" {
+		t.Error("expected no trace id")
+	}
+	span.End()
+}
+
+func TestIsDatadog(t *testing.T) {
+	defer SetTracer(nil)
+	for _, c := range []struct {
+		tr   Tracer
+		want bool
+	}{
+		{nil, false},
+		{NewOTel(sdktrace.NewTracerProvider()), false},
+		{datadog{}, true},
+	} {
+		SetTracer(c.tr)
+		if got := IsDatadog(); got != c.want {
+			t.Errorf("%T: expected %t, got %t", c.tr, c.want, got)
+		}
+	}
+}
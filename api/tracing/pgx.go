@@ -0,0 +1,268 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Returns the name of a query from a "-- name: X" comment
+// on its first line, or an empty string if it isn't named.
+func queryName(sql string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(sql), "\n")
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "--") {
+		return ""
+	}
+	k, v, ok := strings.Cut(strings.TrimPrefix(line, "--"), ":")
+	if !ok || strings.TrimSpace(k) != "name" {
+		return ""
+	}
+	if f := strings.Fields(v); len(f) > 0 {
+		return f[0]
+	}
+	return ""
+}
+
+type queryTracer struct {
+	host string
+}
+
+func (qt queryTracer) start(ctx context.Context, sql string) (context.Context, Span) {
+	name := "pgx"
+	if n := queryName(sql); n != "" {
+		name += "." + n
+	}
+	return Start(ctx, name,
+		WithKind(KindClient),
+		WithAttributes(
+			String("db.system", "postgresql"),
+			String("db.statement", sql),
+			String("net.peer.name", qt.host),
+		),
+	)
+}
+
+// Ends a query's span. A query that finds
+// no rows isn't recorded as an error.
+func (qt queryTracer) end(span Span, rows int64, err error) {
+	span.SetAttributes(Int64("db.rows", rows))
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// DB traces each query run on a pool, or in a transaction begun
+// from it, with a span from the start to the end of the query.
+// The span is named by the query's "-- name: X" comment.
+type DB struct {
+	*pgxpool.Pool
+	qt queryTracer
+}
+
+// NewDB traces the queries run on p. It returns nil if p is nil.
+func NewDB(p *pgxpool.Pool) *DB {
+	if p == nil {
+		return nil
+	}
+	return &DB{
+		Pool: p,
+		qt:   queryTracer{host: p.Config().ConnConfig.Host},
+	}
+}
+
+func (db *DB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return traceExec(ctx, db.qt, db.Pool.Exec, sql, args)
+}
+
+func (db *DB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return traceQuery(ctx, db.qt, db.Pool.Query, sql, args)
+}
+
+func (db *DB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return traceQueryRow(ctx, db.qt, db.Pool.QueryRow, sql, args)
+}
+
+func (db *DB) QueryFunc(ctx context.Context, sql string, args []any, scans []any, f func(pgx.QueryFuncRow) error) (pgconn.CommandTag, error) {
+	return traceQueryFunc(ctx, db.qt, db.Pool.QueryFunc, sql, args, scans, f)
+}
+
+func (db *DB) CopyFrom(ctx context.Context, table pgx.Identifier, cols []string, src pgx.CopyFromSource) (int64, error) {
+	return traceCopyFrom(ctx, db.qt, db.Pool.CopyFrom, table, cols, src)
+}
+
+func (db *DB) Begin(ctx context.Context) (pgx.Tx, error) {
+	t, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tx{Tx: t, qt: db.qt}, nil
+}
+
+func (db *DB) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	t, err := db.Pool.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &tx{Tx: t, qt: db.qt}, nil
+}
+
+type tx struct {
+	pgx.Tx
+	qt queryTracer
+}
+
+func (t *tx) Begin(ctx context.Context) (pgx.Tx, error) {
+	nested, err := t.Tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tx{Tx: nested, qt: t.qt}, nil
+}
+
+func (t *tx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return traceExec(ctx, t.qt, t.Tx.Exec, sql, args)
+}
+
+func (t *tx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return traceQuery(ctx, t.qt, t.Tx.Query, sql, args)
+}
+
+func (t *tx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return traceQueryRow(ctx, t.qt, t.Tx.QueryRow, sql, args)
+}
+
+func (t *tx) QueryFunc(ctx context.Context, sql string, args []any, scans []any, f func(pgx.QueryFuncRow) error) (pgconn.CommandTag, error) {
+	return traceQueryFunc(ctx, t.qt, t.Tx.QueryFunc, sql, args, scans, f)
+}
+
+func (t *tx) CopyFrom(ctx context.Context, table pgx.Identifier, cols []string, src pgx.CopyFromSource) (int64, error) {
+	return traceCopyFrom(ctx, t.qt, t.Tx.CopyFrom, table, cols, src)
+}
+
+func traceExec(
+	ctx context.Context,
+	qt queryTracer,
+	exec func(context.Context, string, ...any) (pgconn.CommandTag, error),
+	sql string,
+	args []any,
+) (pgconn.CommandTag, error) {
+	ctx, span := qt.start(ctx, sql)
+	tag, err := exec(ctx, sql, args...)
+	qt.end(span, tag.RowsAffected(), err)
+	return tag, err
+}
+
+func traceQuery(
+	ctx context.Context,
+	qt queryTracer,
+	query func(context.Context, string, ...any) (pgx.Rows, error),
+	sql string,
+	args []any,
+) (pgx.Rows, error) {
+	ctx, span := qt.start(ctx, sql)
+	rows, err := query(ctx, sql, args...)
+	if err != nil {
+		qt.end(span, 0, err)
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, qt: qt, span: span}, nil
+}
+
+func traceQueryRow(
+	ctx context.Context,
+	qt queryTracer,
+	queryRow func(context.Context, string, ...any) pgx.Row,
+	sql string,
+	args []any,
+) pgx.Row {
+	ctx, span := qt.start(ctx, sql)
+	return tracedRow{row: queryRow(ctx, sql, args...), qt: qt, span: span}
+}
+
+func traceQueryFunc(
+	ctx context.Context,
+	qt queryTracer,
+	queryFunc func(context.Context, string, []any, []any, func(pgx.QueryFuncRow) error) (pgconn.CommandTag, error),
+	sql string,
+	args []any,
+	scans []any,
+	f func(pgx.QueryFuncRow) error,
+) (pgconn.CommandTag, error) {
+	ctx, span := qt.start(ctx, sql)
+	var n int64
+	tag, err := queryFunc(ctx, sql, args, scans, func(r pgx.QueryFuncRow) error {
+		n++
+		return f(r)
+	})
+	qt.end(span, n, err)
+	return tag, err
+}
+
+func traceCopyFrom(
+	ctx context.Context,
+	qt queryTracer,
+	copyFrom func(context.Context, pgx.Identifier, []string, pgx.CopyFromSource) (int64, error),
+	table pgx.Identifier,
+	cols []string,
+	src pgx.CopyFromSource,
+) (int64, error) {
+	ctx, span := qt.start(ctx, "COPY "+table.Sanitize())
+	n, err := copyFrom(ctx, table, cols, src)
+	qt.end(span, n, err)
+	return n, err
+}
+
+// tracedRows ends its span when the rows are
+// read or closed, whichever is first.
+type tracedRows struct {
+	pgx.Rows
+	qt    queryTracer
+	span  Span
+	n     int64
+	ended bool
+}
+
+func (r *tracedRows) Next() bool {
+	if r.Rows.Next() {
+		r.n++
+		return true
+	}
+	r.end()
+	return false
+}
+
+func (r *tracedRows) Close() {
+	r.Rows.Close()
+	r.end()
+}
+
+func (r *tracedRows) end() {
+	if r.ended {
+		return
+	}
+	r.ended = true
+	r.qt.end(r.span, r.n, r.Rows.Err())
+}
+
+// tracedRow ends its span when it is scanned.
+type tracedRow struct {
+	row  pgx.Row
+	qt   queryTracer
+	span Span
+}
+
+func (r tracedRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	var n int64
+	if err == nil {
+		n = 1
+	}
+	r.qt.end(r.span, n, err)
+	return err
+}
@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQueryName(t *testing.T) {
+	cases := []struct {
+		sql  string
+		want string
+	}{
+		{"\n\t\t-- name: GetTree\n\t\tSELECT 1", "GetTree"},
+		{"-- name: GetTree :one\nSELECT 1", "GetTree"},
+		{"--name:GetTree\nSELECT 1", "GetTree"},
+		{"SELECT 1\n-- name: GetTree", ""},
+		{"-- get the tree\nSELECT 1", ""},
+		{"-- name:\nSELECT 1", ""},
+		{"SELECT 1", ""},
+	}
+	for _, c := range cases {
+		if got := queryName(c.sql); got != c.want {
+			t.Errorf("queryName(%q): expected %q, got %q", c.sql, c.want, got)
+		}
+	}
+}
+
+type fakeRow struct{ err error }
+
+func (r fakeRow) Scan(dest ...any) error { return r.err }
+
+func TestQuerySpans(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	SetTracer(NewOTel(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))))
+	defer SetTracer(nil)
+
+	var (
+		ctx    = context.Background()
+		qt     = queryTracer{host: "db"}
+		failed = errors.New("failed")
+	)
+	for _, err := range []error{nil, pgx.ErrNoRows, failed} {
+		row := traceQueryRow(ctx, qt, func(context.Context, string, ...any) pgx.Row {
+			return fakeRow{err}
+		}, "-- name: GetTree\nSELECT 1", nil)
+		if got := row.Scan(); got != err {
+			t.Errorf("expected %v, got %v", err, got)
+		}
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	for i, c := range []struct {
+		rows int64
+		code codes.Code
+	}{
+		{1, codes.Unset},
+		{0, codes.Unset},
+		{0, codes.Error},
+	} {
+		s := spans[i]
+		if s.Name() != "pgx.GetTree" {
+			t.Errorf("expected pgx.GetTree, got %s", s.Name())
+		}
+		var rows int64 = -1
+		for _, kv := range s.Attributes() {
+			if kv.Key == "db.rows" {
+				rows = kv.Value.AsInt64()
+			}
+		}
+		if rows != c.rows {
+			t.Errorf("span %d: expected %d rows, got %d", i, c.rows, rows)
+		}
+		if s.Status().Code != c.code {
+			t.Errorf("span %d: expected status %v, got %v", i, c.code, s.Status().Code)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Package tracing starts spans with the tracer set by
+// [SetTracer], which is Datadog or OpenTelemetry. Spans
+// aren't recorded until a tracer is set.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// An Attr is a span attribute. Values are strings,
+// ints, int64s, float64s or bools.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+func String(k, v string) Attr      { return Attr{k, v} }
+func Int(k string, v int) Attr     { return Attr{k, v} }
+func Int64(k string, v int64) Attr { return Attr{k, v} }
+func Bool(k string, v bool) Attr   { return Attr{k, v} }
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindServer
+	KindClient
+)
+
+type startConfig struct {
+	kind  Kind
+	attrs []Attr
+}
+
+type StartOption func(*startConfig)
+
+func WithKind(k Kind) StartOption {
+	return func(c *startConfig) { c.kind = k }
+}
+
+func WithAttributes(attrs ...Attr) StartOption {
+	return func(c *startConfig) { c.attrs = append(c.attrs, attrs...) }
+}
+
+func newStartConfig(opts []StartOption) startConfig {
+	var c startConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+type Span interface {
+	SetAttributes(attrs ...Attr)
+
+	// RecordError marks the span as failed.
+	RecordError(err error)
+
+	// TraceID returns the id of the span's trace in the
+	// tracer's format, or an empty string if it isn't recorded.
+	TraceID() string
+
+	End()
+}
+
+type Tracer interface {
+	// Start starts a span that is a child of the span in
+	// ctx, or of the remote span added by Extract.
+	Start(ctx context.Context, name string, opts ...StartOption) (context.Context, Span)
+
+	// Extract adds the remote span propagated in h to ctx.
+	Extract(ctx context.Context, h http.Header) context.Context
+
+	// Shutdown flushes spans that haven't been sent.
+	Shutdown(ctx context.Context) error
+}
+
+var (
+	mu     sync.RWMutex
+	global Tracer = noop{}
+)
+
+// SetTracer sets the tracer used by [Start] and [Extract].
+// A nil tracer disables tracing.
+func SetTracer(t Tracer) {
+	if t == nil {
+		t = noop{}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	global = t
+}
+
+func current() Tracer {
+	mu.RLock()
+	defer mu.RUnlock()
+	return global
+}
+
+// Start starts a span with the tracer set by [SetTracer].
+// The span must be ended.
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, Span) {
+	return current().Start(ctx, name, opts...)
+}
+
+// Extract adds the remote span propagated in h to ctx
+// so that spans started with ctx are its children.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	return current().Extract(ctx, h)
+}
+
+// Shutdown flushes the spans of the tracer set by
+// [SetTracer] and stops it.
+func Shutdown(ctx context.Context) error {
+	return current().Shutdown(ctx)
+}
+
+// IsDatadog reports whether the tracer set by [SetTracer]
+// is Datadog, whose trace ids are added to logs as dd.trace_id.
+func IsDatadog() bool {
+	_, ok := current().(datadog)
+	return ok
+}
+
+type noop struct{}
+
+func (noop) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (noop) Extract(ctx context.Context, h http.Header) context.Context { return ctx }
+func (noop) Shutdown(ctx context.Context) error                         { return nil }
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...Attr) {}
+func (noopSpan) RecordError(err error)       {}
+func (noopSpan) TraceID() string             { return "" }
+func (noopSpan) End()                        {}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/contextwtf/lanyard/api/tracing"
+	"github.com/contextwtf/lanyard/merkle"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tracing.SetTracer(tracing.NewOTel(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))))
+	defer tracing.SetTracer(nil)
+
+	td := getTreeResp{
+		UnhashedLeaves: []hexutil.Bytes{{0x01}, {0x02}, {0x03}},
+	}
+	s := New(nil)
+	s.loadTree = func(ctx context.Context, root []byte) (getTreeResp, merkle.Tree, error) {
+		return td, nil, nil
+	}
+	root := hexutil.Bytes(newCachedTree(td, nil).t.Root())
+	r := httptest.NewRequest("GET", "/api/v1/proof?"+url.Values{
+		"root":         {root.String()},
+		"unhashedLeaf": {"0x01"},
+	}.Encode(), nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	s.Handler("production", "").ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range rec.Ended() {
+		spans[span.Name()] = span
+	}
+	for _, c := range []struct{ name, parent string }{
+		{"tree.build", "tree.cache.lookup"},
+		{"tree.cache.lookup", "http.request"},
+		{"http.request", ""},
+	} {
+		span, ok := spans[c.name]
+		if !ok {
+			t.Fatalf("expected a %s span", c.name)
+		}
+		if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("%s: expected the propagated trace, got %s", c.name, got)
+		}
+		want := "00f067aa0ba902b7"
+		if c.parent != "" {
+			want = spans[c.parent].SpanContext().SpanID().String()
+		}
+		if got := span.Parent().SpanID().String(); got != want {
+			t.Errorf("%s: expected parent %s, got %s", c.name, want, got)
+		}
+	}
+}
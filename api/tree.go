@@ -2,17 +2,21 @@ package api
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/contextwtf/lanyard/api/tracing"
 	"github.com/contextwtf/lanyard/merkle"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/jackc/pgx/v4"
-	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/rs/zerolog/log"
 )
 
 func (s *Server) TreeHandler(w http.ResponseWriter, r *http.Request) {
@@ -23,6 +27,9 @@ func (s *Server) TreeHandler(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		s.GetTree(w, r)
 		return
+	case http.MethodDelete:
+		s.DeleteTree(w, r)
+		return
 	default:
 		http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
 		return
@@ -86,13 +93,60 @@ func hashProof(p [][]byte) []byte {
 }
 
 type createTreeReq struct {
-	Leaves []string `json:"unhashedLeaves"`
-	Ltd    []string `json:"leafTypeDescriptor"`
-	Packed bool     `json:"packedEncoding"`
+	Leaves     []string `json:"unhashedLeaves"`
+	Ltd        []string `json:"leafTypeDescriptor"`
+	Packed     bool     `json:"packedEncoding"`
+	Visibility string   `json:"visibility"`
+
+	Metadata *treeMetadata `json:"metadata,omitempty"`
+
+	// trees without a ttl never expire
+	TTLSeconds int64 `json:"ttlSeconds,omitempty"`
 }
 
 type createTreeResp struct {
-	MerkleRoot string `json:"merkleRoot"`
+	MerkleRoot string     `json:"merkleRoot"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+
+	// only set when a private tree is created
+	ReadToken string `json:"readToken,omitempty"`
+}
+
+// The parameters of a new tree after they've been
+// decoded from an http or grpc request.
+type treeParams struct {
+	leaves    [][]byte
+	ltd       []string
+	packed    bool
+	private   bool
+	metadata  *treeMetadata
+	expiresAt *time.Time
+}
+
+// The result of createTree. For an existing tree, readToken
+// is empty and expiresAt is the existing tree's expiry.
+type createdTree struct {
+	root      []byte
+	readToken string
+	expiresAt *time.Time
+}
+
+// Decodes a hex leaf like the go-ethereum FromHex method,
+// which is lenient and allows for odd-length hex strings
+// (by padding them), but rejects invalid hex instead of
+// silently truncating the leaf.
+func decodeLeaf(l string) ([]byte, error) {
+	if has0xPrefix(l) {
+		l = l[2:]
+	}
+	if len(l)%2 == 1 {
+		l = "0" + l
+	}
+	return hex.DecodeString(l)
+}
+
+func has0xPrefix(s string) bool {
+	return len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X')
 }
 
 func (s *Server) CreateTree(w http.ResponseWriter, r *http.Request) {
@@ -101,92 +155,237 @@ func (s *Server) CreateTree(w http.ResponseWriter, r *http.Request) {
 		ctx = r.Context()
 	)
 	defer r.Body.Close()
+	if err := s.authorize(ctx, ScopeTreesWrite); err != nil {
+		s.sendJSONError(r, w, err)
+		return
+	}
+	s.limits.limitBody(w, r)
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.sendJSONError(r, w, err, http.StatusBadRequest, "invalid request body")
+		s.sendJSONError(r, w, decodeBodyError(err))
 		return
 	}
-	switch len(req.Leaves) {
-	case 0:
-		s.sendJSONError(r, w, nil, http.StatusBadRequest, "No leaves provided")
+
+	var leaves [][]byte
+	for i, l := range req.Leaves {
+		leaf, err := decodeLeaf(l)
+		if err != nil {
+			s.sendJSONError(r, w, badRequest(codeInvalidLeaf, "invalid leaf").with(map[string]any{
+				"index":  i,
+				"reason": err.Error(),
+			}))
+			return
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	private, err := parseVisibility(req.Visibility)
+	if err != nil {
+		s.sendJSONError(r, w, err)
 		return
-	case 1:
-		s.sendJSONError(r, w, nil, http.StatusBadRequest, "You must provide at least two values")
+	}
+	if req.Metadata != nil {
+		if err := req.Metadata.validate(); err != nil {
+			s.sendJSONError(r, w, err)
+			return
+		}
+	}
+	exp, err := expiresAt(req.TTLSeconds, time.Now())
+	if err != nil {
+		s.sendJSONError(r, w, err)
 		return
 	}
 
-	var leaves [][]byte
-	for _, l := range req.Leaves {
-		// use the go-ethereum FromHex method because it is more
-		// lenient and will allow for odd-length hex strings (by padding them)
-		leaves = append(leaves, common.FromHex(l))
+	ct, err := s.createTree(ctx, treeParams{
+		leaves:    leaves,
+		ltd:       req.Ltd,
+		packed:    req.Packed,
+		private:   private,
+		metadata:  req.Metadata,
+		expiresAt: exp,
+	})
+	if err != nil {
+		s.sendJSONError(r, w, err)
+		return
+	}
+
+	s.sendJSON(r, w, createTreeResp{
+		MerkleRoot: hexutil.Encode(ct.root),
+		ExpiresAt:  ct.expiresAt,
+		ReadToken:  ct.readToken,
+	})
+}
+
+// Builds the tree and stores it along with the hashes
+// of its proofs, which are omitted for private trees so
+// that they can't be found by reverse lookups. The name
+// of the request's API key, if any, is recorded as the
+// owner of a new tree. An expired tree that hasn't been
+// collected yet is replaced. Creating a tree that exists with
+// a different visibility is a conflict. Metadata is added to an
+// existing tree that doesn't have any; see addTreeMetadata.
+func (s *Server) createTree(ctx context.Context, p treeParams) (createdTree, error) {
+	leaves := p.leaves
+	switch len(leaves) {
+	case 0:
+		return createdTree{}, badRequest(codeTooFewLeaves, "No leaves provided")
+	case 1:
+		return createdTree{}, badRequest(codeTooFewLeaves, "You must provide at least two values")
+	}
+	if err := s.limits.checkTree(p); err != nil {
+		return createdTree{}, err
 	}
 
+	_, span := tracing.Start(ctx, "tree.build", tracing.WithAttributes(
+		tracing.String("tree.source", buildCreate),
+		tracing.Int("tree.leaves", len(leaves)),
+	))
 	var (
-		tree   = merkle.New(leaves)
-		root   = tree.Root()
-		exists bool
+		start = time.Now()
+		tree  = merkle.NewParallel(leaves)
 	)
+	s.metrics.observeBuild(buildCreate, len(leaves), time.Since(start))
+	span.End()
 
-	const existsQ = `
-	select exists(
-		select 1 from trees where root = $1
+	return s.storeTree(ctx, p, tree, true)
+}
+
+// Stores the tree built from p unless it already exists. If
+// another request stores the same tree first, the tree is
+// handled as existing when retry is set, and is otherwise a
+// conflict.
+func (s *Server) storeTree(ctx context.Context, p treeParams, tree merkle.Tree, retry bool) (createdTree, error) {
+	var (
+		leaves      = p.leaves
+		root        = tree.Root()
+		exists      bool
+		expired     bool
+		prevExp     *time.Time
+		prevPrivate bool
+		prevOwner   *string
+		prevMeta    metadataScanner
 	)
+
+	const existsQ = `
+	-- name: GetExistingTree
+	select t.expires_at, coalesce(t.expires_at <= now(), false), t.private, t.owner, ` + metadataColumns + `
+	from trees t
+	left join tree_metadata m on m.root = t.root
+	where t.root = $1
 	`
 
-	err := s.db.QueryRow(ctx, existsQ, root).Scan(&exists)
+	err := s.db.QueryRow(ctx, existsQ, root).Scan(append([]any{
+		&prevExp,
+		&expired,
+		&prevPrivate,
+		&prevOwner,
+	}, prevMeta.dest()...)...)
+	switch {
+	case err == nil:
+		exists = true
+	case !errors.Is(err, pgx.ErrNoRows):
+		return createdTree{}, internalError(err, "failed to check if tree already exists")
+	}
 
-	if err != nil {
-		s.sendJSONError(r, w, err, http.StatusInternalServerError, "failed to check if tree already exists")
-		return
+	// the visibility and expiry of an existing tree
+	// don't change and its read token can't be recovered
+	if exists && !expired {
+		if prevPrivate != p.private {
+			return createdTree{}, conflict(codeTreeExists, "tree already exists with a different visibility").with(map[string]any{
+				"visibility": visibility(prevPrivate),
+			})
+		}
+		if p.metadata != nil {
+			if err := s.addTreeMetadata(ctx, root, prevOwner, prevMeta.metadata(), p.metadata); err != nil {
+				return createdTree{}, err
+			}
+		}
+		return createdTree{root: root, expiresAt: prevExp}, nil
 	}
 
-	if exists {
-		s.sendJSON(r, w, createTreeResp{hexutil.Encode(root)})
-		return
+	var proofHashes [][]any
+	if !p.private {
+		proofHashes = make([][]any, 0, len(leaves))
+		for _, proof := range tree.LeafProofs() {
+			proofHashes = append(proofHashes, []any{root, hashProof(proof)})
+		}
 	}
 
 	var (
-		proofHashes = make([][]any, 0, len(leaves))
-		allProofs   = tree.LeafProofs()
+		token     string
+		tokenHash []byte
 	)
-
-	for _, p := range allProofs {
-		proofHash := hashProof(p)
-		proofHashes = append(proofHashes, []any{root, proofHash})
+	if p.private {
+		token, tokenHash, err = newReadToken()
+		if err != nil {
+			return createdTree{}, internalError(err, "creating read token")
+		}
 	}
 
+	levels, err := tree.MarshalBinary()
 	if err != nil {
-		s.sendJSONError(r, w, err, http.StatusBadRequest, "generating proofs for tree")
-		return
+		return createdTree{}, internalError(err, "encoding tree")
 	}
 
 	const q = `
+		-- name: InsertTree
 		INSERT INTO trees(
 			root,
 			unhashed_leaves,
 			ltd,
-			packed
-		) VALUES ($1, $2, $3, $4)
+			packed,
+			levels,
+			owner,
+			private,
+			read_token_hash,
+			expires_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		ON CONFLICT (root)
 		DO NOTHING
 	`
 
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		s.sendJSONError(r, w, err, http.StatusInternalServerError, "creating transaction")
-		return
+		return createdTree{}, internalError(err, "creating transaction")
 	}
 	defer tx.Rollback(ctx)
 
-	_, err = tx.Exec(ctx, q,
+	if expired {
+		if err := deleteTrees(ctx, tx, [][]byte{root}); err != nil {
+			return createdTree{}, internalError(err, "deleting expired tree")
+		}
+		s.tlru.Remove(common.BytesToHash(root))
+	}
+
+	tag, err := tx.Exec(ctx, q,
 		tree.Root(),
 		leaves,
-		req.Ltd,
-		req.Packed,
+		p.ltd,
+		p.packed,
+		levels,
+		owner(ctx),
+		p.private,
+		tokenHash,
+		p.expiresAt,
 	)
 	if err != nil {
-		s.sendJSONError(r, w, err, http.StatusInternalServerError, "inserting tree")
-		return
+		return createdTree{}, internalError(err, "inserting tree")
+	}
+	if tag.RowsAffected() == 0 {
+		// stored by a concurrent request, whose read token
+		// and proof hashes are the ones that count
+		if err := tx.Rollback(ctx); err != nil {
+			return createdTree{}, internalError(err, "rolling back transaction")
+		}
+		if !retry {
+			return createdTree{}, conflict(codeTreeExists, "tree was created by a concurrent request")
+		}
+		return s.storeTree(ctx, p, tree, false)
+	}
+
+	if p.metadata != nil {
+		if _, err := insertTreeMetadata(ctx, tx, root, p.metadata); err != nil {
+			return createdTree{}, internalError(err, "inserting metadata")
+		}
 	}
 
 	_, err = tx.CopyFrom(ctx, pgx.Identifier{"proofs_hashes"},
@@ -195,17 +394,19 @@ func (s *Server) CreateTree(w http.ResponseWriter, r *http.Request) {
 	)
 
 	if err != nil {
-		s.sendJSONError(r, w, err, http.StatusInternalServerError, "inserting proof hashes")
-		return
+		return createdTree{}, internalError(err, "inserting proof hashes")
 	}
 
 	err = tx.Commit(ctx)
 	if err != nil {
-		s.sendJSONError(r, w, err, http.StatusInternalServerError, "committing transaction")
-		return
+		return createdTree{}, internalError(err, "committing transaction")
 	}
 
-	s.sendJSON(r, w, createTreeResp{hexutil.Encode(root)})
+	return createdTree{
+		root:      root,
+		readToken: token,
+		expiresAt: p.expiresAt,
+	}, nil
 }
 
 type getTreeResp struct {
@@ -213,24 +414,123 @@ type getTreeResp struct {
 	LeafCount      int             `json:"leafCount"`
 	Ltd            []string        `json:"leafTypeDescriptor"`
 	Packed         bool            `json:"packedEncoding"`
+	Metadata       *treeMetadata   `json:"metadata,omitempty"`
+	ExpiresAt      *time.Time      `json:"expiresAt,omitempty"`
+
+	insertedAt    time.Time
+	owner         *string
+	private       bool
+	readTokenHash []byte
 }
 
-func getTree(ctx context.Context, db *pgxpool.Pool, root []byte) (getTreeResp, error) {
+func getTree(ctx context.Context, db *tracing.DB, root []byte) (getTreeResp, error) {
 	const q = `
-		SELECT unhashed_leaves, ltd, packed
+		-- name: GetTree
+		SELECT
+			t.unhashed_leaves,
+			t.ltd,
+			t.packed,
+			t.inserted_at,
+			t.owner,
+			t.private,
+			t.read_token_hash,
+			t.expires_at,
+			` + metadataColumns + `
+		FROM trees t
+		LEFT JOIN tree_metadata m ON m.root = t.root
+		WHERE t.root = $1
+		AND (t.expires_at IS NULL OR t.expires_at > now())
+	`
+	var (
+		tr = getTreeResp{}
+		ms metadataScanner
+	)
+	err := db.QueryRow(ctx, q, root).Scan(append([]any{
+		&tr.UnhashedLeaves,
+		&tr.Ltd,
+		&tr.Packed,
+		&tr.insertedAt,
+		&tr.owner,
+		&tr.private,
+		&tr.readTokenHash,
+		&tr.ExpiresAt,
+	}, ms.dest()...)...)
+	if err != nil {
+		return tr, err
+	}
+	tr.Metadata = ms.metadata()
+	return tr, nil
+}
+
+// Returns when the public tree was inserted
+// without reading its leaves.
+func getTreeInsertedAt(ctx context.Context, db *tracing.DB, root []byte) (time.Time, error) {
+	const q = `
+		-- name: GetTreeInsertedAt
+		SELECT inserted_at
+		FROM trees
+		WHERE root = $1 AND NOT private
+		AND expires_at IS NULL
+	`
+	var t time.Time
+	err := db.QueryRow(ctx, q, root).Scan(&t)
+	return t, err
+}
+
+// Returns when the unexpired tree was inserted, public or
+// private, so that a cached tree can be checked against it.
+func getLiveTreeInsertedAt(ctx context.Context, db *tracing.DB, root []byte) (time.Time, error) {
+	const q = `
+		-- name: GetLiveTreeInsertedAt
+		SELECT inserted_at
+		FROM trees
+		WHERE root = $1
+		AND (expires_at IS NULL OR expires_at > now())
+	`
+	var t time.Time
+	err := db.QueryRow(ctx, q, root).Scan(&t)
+	return t, err
+}
+
+// Like getTree but also returns the tree's persisted levels.
+// The returned tree is nil for trees whose levels
+// haven't been persisted or can't be decoded.
+func getTreeLevels(ctx context.Context, db *tracing.DB, root []byte) (getTreeResp, merkle.Tree, error) {
+	const q = `
+		-- name: GetTreeLevels
+		SELECT unhashed_leaves, ltd, packed, inserted_at, owner, private, read_token_hash, expires_at, levels
 		FROM trees
 		WHERE root = $1
+		AND (expires_at IS NULL OR expires_at > now())
 	`
-	tr := getTreeResp{}
+	var (
+		tr     = getTreeResp{}
+		levels []byte
+	)
 	err := db.QueryRow(ctx, q, root).Scan(
 		&tr.UnhashedLeaves,
 		&tr.Ltd,
 		&tr.Packed,
+		&tr.insertedAt,
+		&tr.owner,
+		&tr.private,
+		&tr.readTokenHash,
+		&tr.ExpiresAt,
+		&levels,
 	)
 	if err != nil {
-		return tr, err
+		return tr, nil, err
 	}
-	return tr, nil
+	if len(levels) == 0 {
+		return tr, nil, nil
+	}
+
+	var t merkle.Tree
+	if err := t.UnmarshalBinary(levels); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("decoding tree levels")
+		return tr, nil, nil
+	}
+	return tr, t, nil
 }
 
 func (s *Server) GetTree(w http.ResponseWriter, r *http.Request) {
@@ -239,23 +539,52 @@ func (s *Server) GetTree(w http.ResponseWriter, r *http.Request) {
 		root = r.URL.Query().Get("root")
 	)
 	if root == "" {
-		s.sendJSONError(r, w, nil, http.StatusBadRequest, "missing root")
+		s.sendJSONError(r, w, badRequest(codeMissingRoot, "missing root"))
 		return
 	}
 
-	tr, err := getTree(ctx, s.db, common.FromHex(root))
+	var (
+		rb  = common.FromHex(root)
+		ct  = responseContentType(r)
+		tag = etag("tree", rb, []byte(ct))
+	)
+	w.Header().Add("Vary", "Accept")
+
+	// avoid reading the leaves when the client already has them
+	if etagMatch(r.Header.Get("If-None-Match"), tag) {
+		insertedAt, err := getTreeInsertedAt(ctx, s.db, rb)
+		if err == nil {
+			w.Header().Set("Cache-Control", "public, max-age=86400")
+			if notModified(w, r, tag, insertedAt) {
+				return
+			}
+		}
+	}
+
+	tr, err := getTree(ctx, s.db, rb)
 
 	if errors.Is(err, pgx.ErrNoRows) {
-		s.sendJSONError(r, w, nil, http.StatusNotFound, "tree not found for root")
-		w.Header().Set("Cache-Control", "public, max-age=60")
+		s.sendJSONError(r, w, notFound(codeTreeNotFound, "tree not found for root"))
 		return
 	} else if err != nil {
-		s.sendJSONError(r, w, err, http.StatusInternalServerError, "selecting tree")
+		s.sendJSONError(r, w, internalError(err, "selecting tree"))
+		return
+	}
+
+	if !s.canRead(ctx, tr, readToken(r)) {
+		s.sendJSONError(r, w, errPrivateTree)
 		return
 	}
 
 	tr.LeafCount = len(tr.UnhashedLeaves)
 
-	w.Header().Set("Cache-Control", "public, max-age=86400")
-	s.sendJSON(r, w, tr)
+	maxAge := capMaxAge(86400, tr.ExpiresAt, time.Now())
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+	if tr.private {
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+	}
+	if notModified(w, r, tag, tr.insertedAt) {
+		return
+	}
+	s.sendEncoded(r, w, tr)
 }
@@ -2,8 +2,11 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"sync"
 	"testing"
 
+	"github.com/contextwtf/lanyard/merkle"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -124,3 +127,52 @@ func TestLeaf2Addr(t *testing.T) {
 	}
 
 }
+
+func TestCreateTreeConcurrently(t *testing.T) {
+	var (
+		s      = testDBServer(t)
+		ctx    = context.Background()
+		leaves = randomLeaves(t, 4)
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		tokens int
+	)
+	for _, private := range []bool{false, true} {
+		leaves, private := leaves, private
+		if private {
+			leaves = randomLeaves(t, 4)
+		}
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tr, err := s.createTree(ctx, treeParams{leaves: leaves, private: private})
+				if err != nil {
+					if ae, ok := err.(*apiError); !ok || ae.code != codeTreeExists {
+						t.Error(err)
+					}
+					return
+				}
+				if tr.readToken != "" {
+					mu.Lock()
+					tokens++
+					mu.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	// only the stored read token is returned
+	if tokens != 1 {
+		t.Errorf("expected 1 read token, got %d", tokens)
+	}
+	const q = `SELECT count(*) FROM proofs_hashes WHERE root = $1`
+	var n int
+	if err := s.db.QueryRow(ctx, q, merkle.New(leaves).Root()).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != len(leaves) {
+		t.Errorf("expected %d proof hashes, got %d", len(leaves), n)
+	}
+}
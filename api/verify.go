@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/contextwtf/lanyard/merkle"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+type verifyReq struct {
+	Root         string   `json:"root"`
+	UnhashedLeaf string   `json:"unhashedLeaf"`
+	Proof        []string `json:"proof"`
+
+	// Values are encoded into the unhashed leaf using
+	// Ltd and Packed when UnhashedLeaf is empty
+	Values []string `json:"values"`
+	Ltd    []string `json:"leafTypeDescriptor"`
+	Packed bool     `json:"packedEncoding"`
+}
+
+type verifyResp struct {
+	Valid        bool          `json:"valid"`
+	UnhashedLeaf hexutil.Bytes `json:"unhashedLeaf"`
+
+	// only set when the proof is invalid
+	LeafHash     hexutil.Bytes `json:"leafHash,omitempty"`
+	ComputedRoot hexutil.Bytes `json:"computedRoot,omitempty"`
+}
+
+func verifyProof(root, leaf []byte, proof [][]byte) verifyResp {
+	resp := verifyResp{
+		Valid:        merkle.Valid(root, proof, leaf),
+		UnhashedLeaf: leaf,
+	}
+	if !resp.Valid {
+		resp.LeafHash = merkle.ProofRoot(nil, leaf)
+		resp.ComputedRoot = merkle.ProofRoot(proof, leaf)
+	}
+	return resp
+}
+
+// VerifyProof checks a proof for a leaf against a root without
+// needing the tree to be published. The leaf may be given
+// unhashed or as a list of values and their types.
+func (s *Server) VerifyProof(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req verifyReq
+	defer r.Body.Close()
+	s.limits.limitBody(w, r)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendJSONError(r, w, decodeBodyError(err))
+		return
+	}
+
+	if req.Root == "" {
+		s.sendJSONError(r, w, badRequest(codeMissingRoot, "missing root"))
+		return
+	}
+	root, err := hexutil.Decode(req.Root)
+	if err != nil {
+		s.sendJSONError(r, w, badRequest(codeMissingRoot, "malformed root"))
+		return
+	}
+
+	var leaf []byte
+	switch {
+	case req.UnhashedLeaf != "":
+		leaf, err = decodeLeaf(req.UnhashedLeaf)
+		if err != nil {
+			s.sendJSONError(r, w, badRequest(codeInvalidLeaf, "malformed unhashedLeaf"))
+			return
+		}
+	case len(req.Values) > 0:
+		leaf, err = merkle.EncodeLeaf(req.Ltd, req.Values, req.Packed)
+		if err != nil {
+			s.sendJSONError(r, w, badRequest(codeInvalidLeaf, "invalid values").with(map[string]any{
+				"reason": err.Error(),
+			}))
+			return
+		}
+	}
+	if len(leaf) == 0 {
+		s.sendJSONError(r, w, badRequest(codeMissingLeaf, "missing leaf"))
+		return
+	}
+
+	var proof [][]byte
+	for i, p := range req.Proof {
+		b, err := hexutil.Decode(p)
+		if err != nil {
+			s.sendJSONError(r, w, badRequest(codeInvalidProof, "malformed proof").with(map[string]any{
+				"index": i,
+			}))
+			return
+		}
+		proof = append(proof, b)
+	}
+
+	s.sendJSON(r, w, verifyProof(root, leaf, proof))
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/contextwtf/lanyard/merkle"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestVerifyProof(t *testing.T) {
+	var (
+		leaves = [][]byte{
+			hexutil.MustDecode("0x00000000000000000000000000000000000000010000000000000000000000000000000000000000000000008ac7230489e80000"),
+			hexutil.MustDecode("0x0000000000000000000000000000000000000002000000000000000000000000000000000000000000000001e5b8fa8fe2ac0000"),
+			hexutil.MustDecode("0x00000000000000000000000000000000000000030000000000000000000000000000000000000000000000000000000000000001"),
+		}
+		tree  = merkle.New(leaves)
+		proof []string
+	)
+	for _, p := range tree.Proof(0) {
+		proof = append(proof, hexutil.Encode(p))
+	}
+
+	cases := []struct {
+		req     verifyReq
+		code    int
+		errCode string
+		valid   bool
+	}{
+		{
+			req: verifyReq{
+				Root:         hexutil.Encode(tree.Root()),
+				UnhashedLeaf: hexutil.Encode(leaves[0]),
+				Proof:        proof,
+			},
+			code:  http.StatusOK,
+			valid: true,
+		},
+		{
+			req: verifyReq{
+				Root:   hexutil.Encode(tree.Root()),
+				Values: []string{"0x0000000000000000000000000000000000000001", "10000000000000000000"},
+				Ltd:    []string{"address", "uint256"},
+				Packed: true,
+				Proof:  proof,
+			},
+			code:  http.StatusOK,
+			valid: true,
+		},
+		{
+			req: verifyReq{
+				Root:         hexutil.Encode(tree.Root()),
+				UnhashedLeaf: hexutil.Encode(leaves[1]),
+				Proof:        proof,
+			},
+			code:  http.StatusOK,
+			valid: false,
+		},
+		{
+			req: verifyReq{
+				Root:  hexutil.Encode(tree.Root()),
+				Proof: proof,
+			},
+			code: http.StatusBadRequest,
+		},
+		{
+			req: verifyReq{
+				Root:   hexutil.Encode(tree.Root()),
+				Values: []string{"1"},
+				Ltd:    []string{"address"},
+				Proof:  proof,
+			},
+			code: http.StatusBadRequest,
+		},
+		{
+			req: verifyReq{
+				Root:         "0xzz",
+				UnhashedLeaf: hexutil.Encode(leaves[0]),
+				Proof:        proof,
+			},
+			code:    http.StatusBadRequest,
+			errCode: codeMissingRoot,
+		},
+		{
+			req: verifyReq{
+				Root:         hexutil.Encode(tree.Root())[:10] + "zz",
+				UnhashedLeaf: hexutil.Encode(leaves[0]),
+				Proof:        proof,
+			},
+			code:    http.StatusBadRequest,
+			errCode: codeMissingRoot,
+		},
+		{
+			req: verifyReq{
+				Root:         hexutil.Encode(tree.Root()),
+				UnhashedLeaf: "0x01zz",
+				Proof:        proof,
+			},
+			code:    http.StatusBadRequest,
+			errCode: codeInvalidLeaf,
+		},
+		{
+			req: verifyReq{
+				Root:         hexutil.Encode(tree.Root()),
+				UnhashedLeaf: hexutil.Encode(leaves[0]),
+				Proof:        append([]string{"0xnothex"}, proof...),
+			},
+			code:    http.StatusBadRequest,
+			errCode: codeInvalidProof,
+		},
+	}
+
+	s := &Server{}
+	for i, c := range cases {
+		body, err := json.Marshal(c.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var (
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest(http.MethodPost, "/api/v1/verify", bytes.NewReader(body))
+		)
+		s.VerifyProof(w, r)
+		if w.Code != c.code {
+			t.Errorf("case %d: expected status %d got %d", i, c.code, w.Code)
+			continue
+		}
+		if c.code != http.StatusOK {
+			var resp errorResp
+			json.NewDecoder(w.Body).Decode(&resp)
+			if c.errCode != "" && resp.Code != c.errCode {
+				t.Errorf("case %d: expected code %s got %s", i, c.errCode, resp.Code)
+			}
+			continue
+		}
+
+		var resp verifyResp
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Valid != c.valid {
+			t.Errorf("case %d: expected valid=%t", i, c.valid)
+		}
+		if !c.valid && (len(resp.LeafHash) == 0 || bytes.Equal(resp.ComputedRoot, tree.Root())) {
+			t.Errorf("case %d: expected diagnostics, got %+v", i, resp)
+		}
+	}
+}
+
+func TestVerifyProofMethod(t *testing.T) {
+	var (
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(http.MethodGet, "/api/v1/verify", strings.NewReader(""))
+	)
+	(&Server{}).VerifyProof(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
@@ -0,0 +1,203 @@
+package lanyard
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Cache stores API responses keyed by request url so
+// that the client can honor the Cache-Control and ETag
+// headers sent by the API. See [WithCache].
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, r *CachedResponse)
+	Delete(key string)
+}
+
+type CachedResponse struct {
+	Body        []byte `json:"body"`
+	ContentType string `json:"contentType,omitempty"`
+	ETag        string `json:"etag,omitempty"`
+
+	// Expires is when the response must be revalidated
+	Expires time.Time `json:"expires"`
+}
+
+func (r *CachedResponse) fresh(now time.Time) bool {
+	return now.Before(r.Expires)
+}
+
+// WithCache caches GET responses in c. Cached responses are used
+// until their max-age expires and are then revalidated using their
+// ETag. Responses marked no-cache are always revalidated and
+// responses marked no-store are never cached.
+func WithCache(c Cache) ClientOpt {
+	return func(cl *Client) {
+		cl.cache = c
+	}
+}
+
+// Returns the cache entry for the response,
+// or nil if it shouldn't be cached.
+func cacheEntry(h http.Header, body []byte, now time.Time) *CachedResponse {
+	var (
+		maxAge  = -1
+		noCache bool
+	)
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		switch {
+		case d == "no-store":
+			return nil
+		case d == "no-cache":
+			noCache = true
+		case strings.HasPrefix(d, "max-age="):
+			if n, err := strconv.Atoi(strings.TrimPrefix(d, "max-age=")); err == nil {
+				maxAge = n
+			}
+		}
+	}
+
+	r := &CachedResponse{
+		Body:        body,
+		ContentType: h.Get("Content-Type"),
+		ETag:        h.Get("ETag"),
+		Expires:     now,
+	}
+	if maxAge > 0 && !noCache {
+		r.Expires = now.Add(time.Duration(maxAge) * time.Second)
+	}
+	// a response that is immediately stale is only
+	// useful if it can be revalidated
+	if !r.fresh(now) && r.ETag == "" {
+		return nil
+	}
+	return r
+}
+
+type memoryCache struct {
+	maxEntries int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key string
+	r   *CachedResponse
+}
+
+// NewMemoryCache returns a Cache that holds up to maxEntries
+// responses in memory, evicting the least recently used.
+func NewMemoryCache(maxEntries int) Cache {
+	return &memoryCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      map[string]*list.Element{},
+	}
+}
+
+func (c *memoryCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*memoryEntry).r, true
+}
+
+func (c *memoryCache) Set(key string, r *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		e.Value.(*memoryEntry).r = r
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&memoryEntry{key: key, r: r})
+	for c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*memoryEntry).key)
+	}
+}
+
+func (c *memoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.ll.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+type diskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a Cache that stores each
+// response as a file in dir, creating dir if needed.
+// The cache is not bounded in size.
+func NewDiskCache(dir string) (Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &diskCache{dir: dir}, nil
+}
+
+func (c *diskCache) path(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(h[:]))
+}
+
+func (c *diskCache) Get(key string) (*CachedResponse, bool) {
+	b, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	r := &CachedResponse{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, false
+	}
+	return r, true
+}
+
+// Writes to a temporary file and renames it so that
+// readers never see a partially written response.
+func (c *diskCache) Set(key string, r *CachedResponse) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	f, err := os.CreateTemp(c.dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+func (c *diskCache) Delete(key string) {
+	os.Remove(c.path(key))
+}
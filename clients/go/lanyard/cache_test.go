@@ -0,0 +1,105 @@
+package lanyard
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Returns a client using cache for a server that responds with
+// the Cache-Control header cc and an ETag, and counts
+// the requests and 304 responses it sends.
+func cacheClient(t *testing.T, cache Cache, cc string) (*Client, *atomic.Int32, *atomic.Int32) {
+	var reqs, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs.Add(1)
+		w.Header().Set("Cache-Control", cc)
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"roots": ["0x01"]}`))
+	}))
+	t.Cleanup(srv.Close)
+	return New(WithURL(srv.URL), WithCache(cache)), &reqs, &notModified
+}
+
+func getRoots(t *testing.T, c *Client) {
+	resp := &RootsResponse{}
+	if err := c.sendRequest(context.Background(), http.MethodGet, "/roots", nil, resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Roots) != 1 || resp.Roots[0].String() != "0x01" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	c, reqs, _ := cacheClient(t, NewMemoryCache(10), "public, max-age=60")
+	getRoots(t, c)
+	getRoots(t, c)
+	if reqs.Load() != 1 {
+		t.Errorf("expected 1 request, got %d", reqs.Load())
+	}
+}
+
+func TestCacheNoCache(t *testing.T) {
+	c, reqs, notModified := cacheClient(t, NewMemoryCache(10), "no-cache")
+	getRoots(t, c)
+	getRoots(t, c)
+	if reqs.Load() != 2 || notModified.Load() != 1 {
+		t.Errorf("expected 2 requests with 1 revalidation, got %d and %d", reqs.Load(), notModified.Load())
+	}
+}
+
+func TestCacheNoStore(t *testing.T) {
+	c, reqs, notModified := cacheClient(t, NewMemoryCache(10), "no-store")
+	getRoots(t, c)
+	getRoots(t, c)
+	if reqs.Load() != 2 || notModified.Load() != 0 {
+		t.Errorf("expected 2 requests without revalidation, got %d and %d", reqs.Load(), notModified.Load())
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	cache, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, reqs, _ := cacheClient(t, cache, "public, max-age=60")
+	getRoots(t, c)
+	getRoots(t, c)
+	if reqs.Load() != 1 {
+		t.Errorf("expected 1 request, got %d", reqs.Load())
+	}
+
+	cache.Delete(c.url + "/roots")
+	if _, ok := cache.Get(c.url + "/roots"); ok {
+		t.Error("expected response to be deleted")
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	var (
+		c = NewMemoryCache(2)
+		r = &CachedResponse{Expires: time.Now().Add(time.Minute)}
+	)
+	c.Set("a", r)
+	c.Set("b", r)
+	c.Get("a")
+	c.Set("c", r)
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected least recently used response to be evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("expected %s to be cached", k)
+		}
+	}
+}
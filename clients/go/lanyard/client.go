@@ -8,21 +8,40 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/contextwtf/lanyard/merkle"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/fxamacker/cbor/v2"
 	"golang.org/x/xerrors"
 )
 
+// ErrNotFound matches, using errors.Is, the [*APIError]
+// returned when the API responds with a 404.
 var ErrNotFound error = xerrors.New("resource not found")
 
+// ErrRootMismatch is returned when the root created by the
+// API doesn't match the root built locally from the same leaves.
+var ErrRootMismatch error = xerrors.New("merkle root does not match locally built tree")
+
+// ErrInvalidProof is returned when a proof received
+// from the API doesn't yield the requested root.
+var ErrInvalidProof error = xerrors.New("proof is not valid for root")
+
 type Client struct {
 	httpClient *http.Client
 	url        string
+	offline    bool
+	retry      retryPolicy
+	cache      Cache
+	binary     bool
+	apiKey     string
+	readTokens readTokens
 }
 
 type ClientOpt func(*Client)
@@ -39,9 +58,38 @@ func WithClient(hc *http.Client) ClientOpt {
 	}
 }
 
-// Uses https://lanyard.org/api/v1 for a default url
-// and http.Client with a 30s timeout unless specified
-// using [WithURL] or [WithClient]
+// WithAPIKey sends key with every request. Servers
+// that require keys reject writes without one.
+func WithAPIKey(key string) ClientOpt {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithOffline makes methods that can be computed
+// locally, such as [Client.VerifyProof], run
+// without contacting the API.
+func WithOffline() ClientOpt {
+	return func(c *Client) {
+		c.offline = true
+	}
+}
+
+const contentTypeCBOR = "application/cbor"
+
+// WithBinaryEncoding requests tree and proof responses in
+// the more compact CBOR encoding instead of JSON. Responses
+// are decoded into the same types either way.
+func WithBinaryEncoding() ClientOpt {
+	return func(c *Client) {
+		c.binary = true
+	}
+}
+
+// Uses https://lanyard.org/api/v1 for a default url,
+// http.Client with a 30s timeout and 3 retries with
+// backoff between 100ms and 5s unless specified
+// using [WithURL], [WithClient] or [WithRetry]
 func New(opts ...ClientOpt) *Client {
 	const url = "https://lanyard.org/api/v1"
 	c := &Client{
@@ -49,6 +97,11 @@ func New(opts ...ClientOpt) *Client {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		retry: retryPolicy{
+			maxRetries: 3,
+			baseDelay:  100 * time.Millisecond,
+			maxDelay:   5 * time.Second,
+		},
 	}
 	for _, opt := range opts {
 		opt(c)
@@ -56,13 +109,18 @@ func New(opts ...ClientOpt) *Client {
 	return c
 }
 
+// errDecode wraps errors decoding a successful
+// response, which aren't worth retrying.
+var errDecode = xerrors.New("failed to decode response")
+
+// Sends the request, retrying failures according
+// to the client's retry policy. See [WithRetry].
 func (c *Client) sendRequest(
 	ctx context.Context,
 	method, path string,
 	body, destination any,
 ) error {
 	var (
-		url   string = c.url + path
 		jsonb []byte
 		err   error
 	)
@@ -74,6 +132,50 @@ func (c *Client) sendRequest(
 		}
 	}
 
+	for attempt := 0; ; attempt++ {
+		err = c.doRequest(ctx, method, path, jsonb, destination)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+
+		delay, ok := c.retry.backoff(attempt, isIdempotent(method), err)
+		if !ok {
+			return err
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Client) doRequest(
+	ctx context.Context,
+	method, path string,
+	jsonb []byte,
+	destination any,
+) error {
+	var (
+		url       = c.url + path
+		cacheable = c.cache != nil && method == http.MethodGet
+		cached    *CachedResponse
+	)
+
+	if cacheable {
+		if r, ok := c.cache.Get(url); ok {
+			if r.fresh(time.Now()) {
+				return decodeResponse(r.ContentType, r.Body, destination)
+			}
+			if r.ETag != "" {
+				cached = r
+			}
+		}
+	}
+
 	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(jsonb))
 	if err != nil {
 		return xerrors.Errorf("error creating request: %w", err)
@@ -81,27 +183,94 @@ func (c *Client) sendRequest(
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "lanyard-go+v1.0.3")
+	if c.apiKey != "" {
+		req.Header.Set("X-Api-Key", c.apiKey)
+	}
+	if token := c.readTokens.forPath(path); token != "" {
+		req.Header.Set("X-Read-Token", token)
+	}
+	if cached != nil {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	if c.binary {
+		req.Header.Set("Accept", contentTypeCBOR+", application/json;q=0.9")
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return xerrors.Errorf("failed to send request: %w", err)
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		if resp.Header.Get("ETag") == "" {
+			resp.Header.Set("ETag", cached.ETag)
+		}
+		resp.Header.Set("Content-Type", cached.ContentType)
+		if r := cacheEntry(resp.Header, cached.Body, time.Now()); r != nil {
+			c.cache.Set(url, r)
+		}
+		return decodeResponse(cached.ContentType, cached.Body, destination)
+	}
 
 	if resp.StatusCode >= 400 {
-		// special case 404s to make consuming client API easier
-		if resp.StatusCode == http.StatusNotFound {
-			return ErrNotFound
+		apiErr := &APIError{
+			StatusCode: resp.StatusCode,
+			Message:    http.StatusText(resp.StatusCode),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+
+		// the API responds with {"error": true, "code": "...", "message": "..."}
+		var errResp struct {
+			Code      string         `json:"code"`
+			Message   string         `json:"message"`
+			RequestID string         `json:"requestId"`
+			Details   map[string]any `json:"details"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil {
+			if errResp.Message != "" {
+				apiErr.Message = errResp.Message
+			}
+			apiErr.Code = errResp.Code
+			apiErr.RequestID = errResp.RequestID
+			apiErr.Details = errResp.Details
 		}
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = resp.Header.Get("Request-Id")
+		}
+		return apiErr
+	}
 
-		return xerrors.Errorf("error making http request: %s", resp.Status)
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
 	}
 
-	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return xerrors.Errorf("failed to read response: %w", err)
+	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&destination); err != nil {
-		return xerrors.Errorf("failed to decode response: %w", err)
+	if cacheable {
+		if r := cacheEntry(resp.Header, body, time.Now()); r != nil {
+			c.cache.Set(url, r)
+		} else {
+			c.cache.Delete(url)
+		}
 	}
 
+	return decodeResponse(resp.Header.Get("Content-Type"), body, destination)
+}
+
+func decodeResponse(contentType string, body []byte, destination any) error {
+	var err error
+	if strings.HasPrefix(contentType, contentTypeCBOR) {
+		err = cbor.Unmarshal(body, destination)
+	} else {
+		err = json.Unmarshal(body, &destination)
+	}
+	if err != nil {
+		return xerrors.Errorf("%w: %v", errDecode, err)
+	}
 	return nil
 }
 
@@ -109,34 +278,42 @@ type createTreeRequest struct {
 	UnhashedLeaves     []hexutil.Bytes `json:"unhashedLeaves"`
 	LeafTypeDescriptor []string        `json:"leafTypeDescriptor,omitempty"`
 	PackedEncoding     bool            `json:"packedEncoding"`
+	Visibility         string          `json:"visibility,omitempty"`
+	Metadata           *TreeMetadata   `json:"metadata,omitempty"`
+	TTLSeconds         int64           `json:"ttlSeconds,omitempty"`
 }
 
 type CreateResponse struct {
 	// MerkleRoot is the root of the created merkle tree
 	MerkleRoot hexutil.Bytes `json:"merkleRoot"`
+
+	// ReadToken is only set when a new private tree is
+	// created. It can't be retrieved again. See [Private].
+	ReadToken string `json:"readToken,omitempty"`
+
+	// ExpiresAt is nil for trees that never expire. See [TTL].
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 }
 
 // If you have a list of addresses for an allowlist, you can
 // create a Merkle tree using CreateTree. Any Merkle tree
 // published on Lanyard will be publicly available to any
 // user of Lanyard’s API, including minting interfaces such
-// as Zora or mint.fun.
+// as Zora or mint.fun, unless it is created with [Private].
 func (c *Client) CreateTree(
 	ctx context.Context,
 	addresses []hexutil.Bytes,
+	opts ...TreeOpt,
 ) (*CreateResponse, error) {
 	req := &createTreeRequest{
 		UnhashedLeaves: addresses,
 		PackedEncoding: true,
 	}
-
-	resp := &CreateResponse{}
-	err := c.sendRequest(ctx, http.MethodPost, "/tree", req, resp)
-	if err != nil {
-		return nil, err
+	for _, opt := range opts {
+		opt(req)
 	}
 
-	return resp, nil
+	return c.createTree(ctx, req)
 }
 
 // CreateTypedTree is a more advanced way of creating a tree.
@@ -146,28 +323,79 @@ func (c *Client) CreateTree(
 // leafTypeDescriptor describes the abi-encoded types of the leaves, and
 // is required if leaves are not address types.
 // Set packedEncoding to true if your arguments are packed/encoded
+// opts set the visibility and metadata of the tree, e.g.
+// [Private], [Name] or [Tags].
 func (c *Client) CreateTypedTree(
 	ctx context.Context,
 	unhashedLeaves []hexutil.Bytes,
 	leafTypeDescriptor []string,
 	packedEncoding bool,
+	opts ...TreeOpt,
 ) (*CreateResponse, error) {
 	req := &createTreeRequest{
 		UnhashedLeaves:     unhashedLeaves,
 		LeafTypeDescriptor: leafTypeDescriptor,
 		PackedEncoding:     packedEncoding,
 	}
+	for _, opt := range opts {
+		opt(req)
+	}
 
-	resp := &CreateResponse{}
+	return c.createTree(ctx, req)
+}
+
+// Builds the tree locally before creating it so that
+// the root returned by the API can be checked.
+func (c *Client) createTree(
+	ctx context.Context,
+	req *createTreeRequest,
+) (*CreateResponse, error) {
+	tree, err := BuildTreeLocal(req.UnhashedLeaves)
+	if err != nil {
+		return nil, err
+	}
 
-	err := c.sendRequest(ctx, http.MethodPost, "/tree", req, resp)
+	resp := &CreateResponse{}
+	err = c.sendRequest(ctx, http.MethodPost, "/tree", req, resp)
 	if err != nil {
 		return nil, err
 	}
 
+	if !bytes.Equal(resp.MerkleRoot, tree.Root()) {
+		return nil, xerrors.Errorf(
+			"%w: got %s want %s",
+			ErrRootMismatch, resp.MerkleRoot, hexutil.Bytes(tree.Root()),
+		)
+	}
+
+	if resp.ReadToken != "" {
+		c.readTokens.set(resp.MerkleRoot, resp.ReadToken)
+	} else if req.Visibility == VisibilityPrivate {
+		return nil, xerrors.Errorf(
+			"%w: the read token of private tree %s can't be recovered",
+			ErrTreeExists, resp.MerkleRoot,
+		)
+	}
+
 	return resp, nil
 }
 
+// BuildTreeLocal builds the merkle tree for the
+// leaves without contacting the API. The root of the
+// tree is the root that [Client.CreateTree] and
+// [Client.CreateTypedTree] will return for the same leaves.
+func BuildTreeLocal(unhashedLeaves []hexutil.Bytes) (merkle.Tree, error) {
+	if len(unhashedLeaves) < 2 {
+		return nil, xerrors.New("at least two leaves are required")
+	}
+
+	leaves := make([][]byte, len(unhashedLeaves))
+	for i, l := range unhashedLeaves {
+		leaves[i] = l
+	}
+	return merkle.New(leaves), nil
+}
+
 type TreeResponse struct {
 	// UnhashedLeaves is a slice of addresses or ABI encoded types
 	UnhashedLeaves []hexutil.Bytes `json:"unhashedLeaves"`
@@ -180,12 +408,20 @@ type TreeResponse struct {
 	PackedEncoding bool `json:"packedEncoding"`
 
 	LeafCount int `json:"leafCount"`
+
+	// Metadata is nil if the tree was created without any
+	Metadata *TreeMetadata `json:"metadata,omitempty"`
+
+	// ExpiresAt is nil for trees that never expire
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 }
 
 // If a Merkle tree has been published to Lanyard, GetTreeFromRoot
 // will return the entire tree based on the root.
-// This endpoint will return ErrNotFound if the tree
-// associated with the root has not been published.
+// This endpoint will return ErrNotFound and ErrTreeNotFound
+// if the tree associated with the root has not been published,
+// and ErrPrivateTree if the tree is private and the client
+// doesn't have its read token. See [Private].
 func (c *Client) GetTreeFromRoot(
 	ctx context.Context,
 	root hexutil.Bytes,
@@ -214,7 +450,9 @@ type ProofResponse struct {
 // GetProofFromLeaf will return the proof associated
 // with an unhashedLeaf. This endpoint will return
 // ErrNotFound if the tree associated with the root
-// has not been published.
+// has not been published (ErrTreeNotFound) or doesn't
+// contain the leaf (ErrLeafNotFound). The proof is checked
+// against root and ErrInvalidProof is returned if it isn't valid.
 func (c *Client) GetProofFromLeaf(
 	ctx context.Context,
 	root, unhashedLeaf hexutil.Bytes,
@@ -233,6 +471,14 @@ func (c *Client) GetProofFromLeaf(
 		return nil, err
 	}
 
+	if !bytes.Equal(resp.UnhashedLeaf, unhashedLeaf) {
+		return nil, xerrors.Errorf("%w: proof is for a different leaf", ErrInvalidProof)
+	}
+
+	if err := validateProof(root, resp); err != nil {
+		return nil, err
+	}
+
 	return resp, nil
 }
 
@@ -240,7 +486,11 @@ func (c *Client) GetProofFromLeaf(
 // GetProofFromAddr will return the proof associated
 // with an address. This endpoint will return
 // ErrNotFound if the tree associated with the root
-// has not been published.
+// has not been published (ErrTreeNotFound) or doesn't
+// contain the leaf (ErrLeafNotFound). The proof is checked
+// against root and ErrInvalidProof is returned if it isn't valid.
+// Searching a private tree by address requires its read
+// token, otherwise ErrPrivateTree is returned.
 func (c *Client) GetProofFromAddr(
 	ctx context.Context,
 	root, addr hexutil.Bytes,
@@ -259,9 +509,27 @@ func (c *Client) GetProofFromAddr(
 		return nil, err
 	}
 
+	if err := validateProof(root, resp); err != nil {
+		return nil, err
+	}
+
 	return resp, nil
 }
 
+func validateProof(root hexutil.Bytes, resp *ProofResponse) error {
+	var proof [][]byte
+	for _, p := range resp.Proof {
+		proof = append(proof, p)
+	}
+	if !merkle.Valid(root, proof, resp.UnhashedLeaf) {
+		return xerrors.Errorf(
+			"%w: got root %s want %s",
+			ErrInvalidProof, hexutil.Bytes(merkle.ProofRoot(proof, resp.UnhashedLeaf)), root,
+		)
+	}
+	return nil
+}
+
 type RootsResponse struct {
 	Roots []hexutil.Bytes `json:"roots"`
 }
@@ -272,7 +540,7 @@ type RootsResponse struct {
 // ErrNotFound if the tree associated with the
 // leaf has not been published. This API response is deprecated
 // as there may be more than one root per proof. Use GetRootsFromProof
-// instead.
+// instead. The roots of private trees are never returned.
 func (c *Client) GetRootsFromProof(
 	ctx context.Context,
 	proof []hexutil.Bytes,
@@ -303,6 +571,78 @@ func (c *Client) GetRootsFromProof(
 	return resp, nil
 }
 
+type VerifyRequest struct {
+	Root  hexutil.Bytes   `json:"root"`
+	Proof []hexutil.Bytes `json:"proof"`
+
+	// UnhashedLeaf is the leaf to verify. If it is empty,
+	// the leaf is encoded from Values using
+	// LeafTypeDescriptor and PackedEncoding
+	UnhashedLeaf       hexutil.Bytes `json:"unhashedLeaf,omitempty"`
+	Values             []string      `json:"values,omitempty"`
+	LeafTypeDescriptor []string      `json:"leafTypeDescriptor,omitempty"`
+	PackedEncoding     bool          `json:"packedEncoding"`
+}
+
+type VerifyResponse struct {
+	Valid        bool          `json:"valid"`
+	UnhashedLeaf hexutil.Bytes `json:"unhashedLeaf"`
+
+	// LeafHash and ComputedRoot are only set when
+	// the proof is invalid to help diagnose it
+	LeafHash     hexutil.Bytes `json:"leafHash,omitempty"`
+	ComputedRoot hexutil.Bytes `json:"computedRoot,omitempty"`
+}
+
+// VerifyProof checks that a proof for a leaf yields the root.
+// The tree doesn't need to be published to Lanyard.
+// If the client was created using [WithOffline], the proof
+// is verified locally and the API isn't contacted.
+func (c *Client) VerifyProof(
+	ctx context.Context,
+	req *VerifyRequest,
+) (*VerifyResponse, error) {
+	if c.offline {
+		return VerifyLocal(req)
+	}
+
+	resp := &VerifyResponse{}
+	err := c.sendRequest(ctx, http.MethodPost, "/verify", req, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// VerifyLocal checks that a proof for a leaf yields
+// the root without contacting the API.
+func VerifyLocal(req *VerifyRequest) (*VerifyResponse, error) {
+	leaf := []byte(req.UnhashedLeaf)
+	if len(leaf) == 0 {
+		var err error
+		leaf, err = merkle.EncodeLeaf(req.LeafTypeDescriptor, req.Values, req.PackedEncoding)
+		if err != nil {
+			return nil, xerrors.Errorf("encoding leaf: %w", err)
+		}
+	}
+
+	var proof [][]byte
+	for _, p := range req.Proof {
+		proof = append(proof, p)
+	}
+
+	resp := &VerifyResponse{
+		Valid:        merkle.Valid(req.Root, proof, leaf),
+		UnhashedLeaf: leaf,
+	}
+	if !resp.Valid {
+		resp.LeafHash = merkle.ProofRoot(nil, leaf)
+		resp.ComputedRoot = merkle.ProofRoot(proof, leaf)
+	}
+	return resp, nil
+}
+
 // With a given leaf and type descriptor, decode an address
 func Leaf2Addr(leaf []byte, ltd []string, packed bool) common.Address {
 	if len(ltd) == 0 || (len(ltd) == 1 && ltd[0] == "address") {
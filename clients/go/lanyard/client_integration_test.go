@@ -4,6 +4,7 @@ package lanyard
 
 import (
 	"context"
+	"errors"
 	"os"
 	"testing"
 
@@ -74,7 +75,7 @@ func TestBasicMerkleProof(t *testing.T) {
 
 func TestBasicMerkleProof404(t *testing.T) {
 	_, err := client.GetProofFromLeaf(context.Background(), []byte{0x01}, hexutil.MustDecode("0x0000000000000000000000000000000000000001"))
-	if err != ErrNotFound {
+	if !errors.Is(err, ErrNotFound) {
 		t.Fatal("expected custom 404 err type for invalid request, got %w", err)
 	}
 }
@@ -120,3 +121,36 @@ func TestGetTree(t *testing.T) {
 		t.Fatalf("expected %d, got %d", len(basicMerkle), tree.LeafCount)
 	}
 }
+
+func TestVerifyProof(t *testing.T) {
+	p, err := client.GetProofFromLeaf(context.Background(), hexutil.MustDecode(basicRoot), basicMerkle[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []*Client{client, New(WithOffline())} {
+		resp, err := c.VerifyProof(context.Background(), &VerifyRequest{
+			Root:         hexutil.MustDecode(basicRoot),
+			UnhashedLeaf: basicMerkle[0],
+			Proof:        p.Proof,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !resp.Valid {
+			t.Fatalf("expected valid proof, computed root %s", resp.ComputedRoot)
+		}
+
+		resp, err = c.VerifyProof(context.Background(), &VerifyRequest{
+			Root:         hexutil.MustDecode(basicRoot),
+			UnhashedLeaf: basicMerkle[1],
+			Proof:        p.Proof,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Valid || len(resp.ComputedRoot) == 0 {
+			t.Fatal("expected invalid proof with computed root")
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package lanyard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/fxamacker/cbor/v2"
+)
+
+var testLeaves = []hexutil.Bytes{
+	hexutil.MustDecode("0x0000000000000000000000000000000000000001"),
+	hexutil.MustDecode("0x0000000000000000000000000000000000000002"),
+	hexutil.MustDecode("0x0000000000000000000000000000000000000003"),
+}
+
+// Returns a client for a server that responds
+// to every request with resp.
+func testClient(t *testing.T, resp any) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return New(WithURL(srv.URL))
+}
+
+func TestCreateTreeRootMismatch(t *testing.T) {
+	tree, err := BuildTreeLocal(testLeaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := testClient(t, CreateResponse{MerkleRoot: tree.Root()})
+	if _, err := c.CreateTree(context.Background(), testLeaves); err != nil {
+		t.Fatal(err)
+	}
+
+	c = testClient(t, CreateResponse{MerkleRoot: make([]byte, 32)})
+	if _, err := c.CreateTree(context.Background(), testLeaves); !errors.Is(err, ErrRootMismatch) {
+		t.Fatalf("expected ErrRootMismatch, got %v", err)
+	}
+}
+
+func TestGetProofValidation(t *testing.T) {
+	tree, err := BuildTreeLocal(testLeaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var proof []hexutil.Bytes
+	for _, p := range tree.Proof(0) {
+		proof = append(proof, p)
+	}
+
+	c := testClient(t, ProofResponse{UnhashedLeaf: testLeaves[0], Proof: proof})
+	if _, err := c.GetProofFromLeaf(context.Background(), tree.Root(), testLeaves[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetProofFromAddr(context.Background(), tree.Root(), testLeaves[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	c = testClient(t, ProofResponse{UnhashedLeaf: testLeaves[1], Proof: proof})
+	if _, err := c.GetProofFromAddr(context.Background(), tree.Root(), testLeaves[1]); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("expected ErrInvalidProof, got %v", err)
+	}
+	if _, err := c.GetProofFromLeaf(context.Background(), tree.Root(), testLeaves[0]); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("expected ErrInvalidProof, got %v", err)
+	}
+}
+
+func TestVerifyLocal(t *testing.T) {
+	tree, err := BuildTreeLocal(testLeaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var proof []hexutil.Bytes
+	for _, p := range tree.Proof(2) {
+		proof = append(proof, p)
+	}
+
+	resp, err := VerifyLocal(&VerifyRequest{
+		Root:               tree.Root(),
+		Proof:              proof,
+		Values:             []string{"0x0000000000000000000000000000000000000003"},
+		LeafTypeDescriptor: []string{"address"},
+		PackedEncoding:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Valid {
+		t.Fatalf("expected valid proof, computed root %s", resp.ComputedRoot)
+	}
+
+	resp, err = VerifyLocal(&VerifyRequest{
+		Root:         tree.Root(),
+		Proof:        proof,
+		UnhashedLeaf: testLeaves[0],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Valid || len(resp.LeafHash) == 0 || len(resp.ComputedRoot) == 0 {
+		t.Fatalf("expected invalid proof with diagnostics, got %+v", resp)
+	}
+}
+
+func TestBuildTreeLocalTooFewLeaves(t *testing.T) {
+	if _, err := BuildTreeLocal(testLeaves[:1]); err == nil {
+		t.Fatal("expected error for a single leaf")
+	}
+}
+
+func TestBinaryEncoding(t *testing.T) {
+	want := TreeResponse{
+		UnhashedLeaves:     testLeaves,
+		LeafTypeDescriptor: []string{"address"},
+		PackedEncoding:     true,
+		LeafCount:          len(testLeaves),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Accept"), contentTypeCBOR) {
+			t.Errorf("expected cbor to be requested, got %s", r.Header.Get("Accept"))
+		}
+		b, err := cbor.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", contentTypeCBOR)
+		w.Write(b)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(WithURL(srv.URL), WithBinaryEncoding())
+	got, err := c.GetTreeFromRoot(context.Background(), hexutil.Bytes{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LeafCount != want.LeafCount || got.UnhashedLeaves[2].String() != want.UnhashedLeaves[2].String() {
+		t.Errorf("expected %+v got %+v", want, got)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": true, "code": "UNAUTHORIZED", "message": "missing api key"}`))
+			return
+		}
+		w.Write([]byte(`{"roots": ["0x01"]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(WithURL(srv.URL), WithAPIKey("secret"))
+	if _, err := c.GetRootsFromProof(context.Background(), []hexutil.Bytes{{1}}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,123 @@
+package lanyard
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// The codes sent by the API with every error response.
+// See [APIError.Code].
+const (
+	CodeInvalidRequest = "INVALID_REQUEST"
+	CodeMissingRoot    = "MISSING_ROOT"
+	CodeMissingLeaf    = "MISSING_LEAF"
+	CodeInvalidLeaf    = "INVALID_LEAF"
+	CodeInvalidProof   = "INVALID_PROOF"
+	CodeTooFewLeaves   = "TOO_FEW_LEAVES"
+	CodeTreeNotFound   = "TREE_NOT_FOUND"
+	CodeLeafNotFound   = "LEAF_NOT_FOUND"
+	CodeRootNotFound   = "ROOT_NOT_FOUND"
+	CodeUnauthorized   = "UNAUTHORIZED"
+	CodeForbidden      = "FORBIDDEN"
+	CodePrivateTree    = "PRIVATE_TREE"
+	CodeRateLimited    = "RATE_LIMITED"
+	CodeBodyTooLarge   = "BODY_TOO_LARGE"
+	CodeTooManyLeaves  = "TOO_MANY_LEAVES"
+	CodeTreeExists     = "TREE_EXISTS"
+	CodeInternal       = "INTERNAL"
+)
+
+var (
+	// ErrTreeNotFound matches, using errors.Is, the [*APIError]
+	// returned when no tree has the requested root.
+	ErrTreeNotFound error = xerrors.New("tree not found")
+
+	// ErrLeafNotFound matches, using errors.Is, the [*APIError]
+	// returned when the leaf or address isn't in the tree.
+	ErrLeafNotFound error = xerrors.New("leaf not found in tree")
+
+	// ErrRootNotFound matches, using errors.Is, the [*APIError]
+	// returned when no tree contains the proof.
+	ErrRootNotFound error = xerrors.New("root not found for proof")
+
+	// ErrInvalidLeaf matches, using errors.Is, the [*APIError]
+	// returned when a leaf or the values used to encode it are
+	// malformed. The details of the error describe the leaf.
+	ErrInvalidLeaf error = xerrors.New("invalid leaf")
+
+	// ErrPrivateTree matches, using errors.Is, the [*APIError]
+	// returned when reading a private tree without its read
+	// token. See [Private].
+	ErrPrivateTree error = xerrors.New("tree is private")
+
+	// ErrRateLimited matches, using errors.Is, the [*APIError]
+	// returned when the client's API key or IP has made too
+	// many requests. The client retries these after the delay
+	// in [APIError.RetryAfter]; see [WithRetry].
+	ErrRateLimited error = xerrors.New("rate limit exceeded")
+
+	// ErrTreeExists matches, using errors.Is, the [*APIError]
+	// returned when creating a tree that already exists with a
+	// different visibility or metadata. It is also returned when creating a
+	// private tree that already exists, since its read token
+	// can't be recovered.
+	ErrTreeExists error = xerrors.New("tree already exists")
+)
+
+var codeErrors = map[string]error{
+	CodeTreeNotFound: ErrTreeNotFound,
+	CodeLeafNotFound: ErrLeafNotFound,
+	CodeRootNotFound: ErrRootNotFound,
+	CodeInvalidLeaf:  ErrInvalidLeaf,
+	CodePrivateTree:  ErrPrivateTree,
+	CodeRateLimited:  ErrRateLimited,
+	CodeTreeExists:   ErrTreeExists,
+}
+
+// APIError is returned when the API responds with an error status.
+// It matches [ErrNotFound] using errors.Is for 404 responses, and
+// the error for its code, e.g. [ErrTreeNotFound], when there is one.
+type APIError struct {
+	StatusCode int
+
+	// Code is the machine-readable code sent by the API,
+	// e.g. [CodeTreeNotFound], or empty if it didn't send one
+	Code string
+
+	// Message is the message sent by the API, or the
+	// status text if the response didn't include one
+	Message string
+
+	// RequestID identifies the request in the API's logs
+	RequestID string
+
+	// Details are optional and depend on the code, e.g.
+	// the index of the invalid leaf for [CodeInvalidLeaf]
+	Details map[string]any
+
+	// RetryAfter is the delay requested by the API's
+	// Retry-After header, or 0 if it wasn't set
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("error making http request: %d %s", e.StatusCode, e.Message)
+	if e.Code != "" {
+		msg += " (" + e.Code + ")"
+	}
+	if e.RequestID != "" {
+		msg += " request " + e.RequestID
+	}
+	return msg
+}
+
+func (e *APIError) Is(target error) bool {
+	if target == ErrNotFound && e.StatusCode == http.StatusNotFound {
+		return true
+	}
+	err, ok := codeErrors[e.Code]
+	return ok && target == err
+}
@@ -0,0 +1,68 @@
+package lanyard
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIErrorCodes(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		is     []error
+		isNot  []error
+		index  any
+	}{
+		{
+			status: http.StatusNotFound,
+			body:   `{"error": true, "code": "TREE_NOT_FOUND", "message": "tree not found", "requestId": "abc"}`,
+			is:     []error{ErrNotFound, ErrTreeNotFound},
+			isNot:  []error{ErrLeafNotFound},
+		},
+		{
+			status: http.StatusNotFound,
+			body:   `{"error": true, "code": "LEAF_NOT_FOUND", "message": "leaf not found in tree", "requestId": "abc"}`,
+			is:     []error{ErrNotFound, ErrLeafNotFound},
+			isNot:  []error{ErrTreeNotFound},
+		},
+		{
+			status: http.StatusBadRequest,
+			body:   `{"error": true, "code": "INVALID_LEAF", "message": "invalid leaf", "requestId": "abc", "details": {"index": 1}}`,
+			is:     []error{ErrInvalidLeaf},
+			isNot:  []error{ErrNotFound},
+			index:  float64(1),
+		},
+	}
+
+	for _, c := range cases {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(c.status)
+			w.Write([]byte(c.body))
+		}))
+		cl := New(WithURL(srv.URL), WithRetry(0, 0, 0))
+		err := cl.sendRequest(context.Background(), http.MethodGet, "/tree", nil, &TreeResponse{})
+		srv.Close()
+
+		for _, target := range c.is {
+			if !errors.Is(err, target) {
+				t.Errorf("expected %v to match %v", err, target)
+			}
+		}
+		for _, target := range c.isNot {
+			if errors.Is(err, target) {
+				t.Errorf("expected %v not to match %v", err, target)
+			}
+		}
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.RequestID != "abc" {
+			t.Errorf("expected APIError with request id, got %v", err)
+		} else if c.index != nil && apiErr.Details["index"] != c.index {
+			t.Errorf("expected details to include the leaf index, got %v", apiErr.Details)
+		}
+	}
+}
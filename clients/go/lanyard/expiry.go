@@ -0,0 +1,30 @@
+package lanyard
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// TTL deletes the tree ttl after it is created, rounded
+// down to the second. Trees without a ttl never expire.
+// Requests for an expired tree return ErrTreeNotFound.
+func TTL(ttl time.Duration) TreeOpt {
+	return func(r *createTreeRequest) {
+		r.TTLSeconds = int64(ttl / time.Second)
+	}
+}
+
+// DeleteTree deletes a tree created with the client's API key.
+// See [WithAPIKey]. Deleting a tree that doesn't exist or that
+// was created with another key returns ErrTreeNotFound.
+func (c *Client) DeleteTree(ctx context.Context, root hexutil.Bytes) error {
+	return c.sendRequest(
+		ctx, http.MethodDelete,
+		fmt.Sprintf("/tree?root=%s", root.String()),
+		nil, nil,
+	)
+}
@@ -0,0 +1,61 @@
+package lanyard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTTL(t *testing.T) {
+	tree, err := BuildTreeLocal(testLeaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		exp = time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+		got createTreeRequest
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(CreateResponse{MerkleRoot: tree.Root(), ExpiresAt: &exp})
+	}))
+	t.Cleanup(srv.Close)
+
+	resp, err := New(WithURL(srv.URL)).CreateTree(context.Background(), testLeaves, TTL(90*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.TTLSeconds != 5400 {
+		t.Errorf("expected ttlSeconds 5400, got %d", got.TTLSeconds)
+	}
+	if resp.ExpiresAt == nil || !resp.ExpiresAt.Equal(exp) {
+		t.Errorf("expected expiry %s, got %v", exp, resp.ExpiresAt)
+	}
+}
+
+func TestDeleteTree(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.Header.Get("X-Api-Key") != "key" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if r.URL.Query().Get("root") == "0x02" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": true, "code": "TREE_NOT_FOUND", "message": "tree not found for root"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(WithURL(srv.URL), WithAPIKey("key"))
+	if err := c.DeleteTree(context.Background(), []byte{0x01}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteTree(context.Background(), []byte{0x02}); !errors.Is(err, ErrTreeNotFound) {
+		t.Errorf("expected ErrTreeNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package lanyard
+
+import (
+	"context"
+	"net/http"
+)
+
+// Limits are the server's limits on the trees it creates.
+// A zero value is disabled.
+type Limits struct {
+	// MaxBodyBytes bounds the JSON body of
+	// [Client.CreateTree] and [Client.VerifyProof].
+	MaxBodyBytes int64 `json:"maxBodyBytes"`
+	MaxLeaves    int   `json:"maxLeaves"`
+
+	// MaxLeafBytes bounds each unhashed leaf.
+	MaxLeafBytes int `json:"maxLeafBytes"`
+
+	// MaxLtdLength bounds the number of types
+	// in the leaf type descriptor.
+	MaxLtdLength int `json:"maxLeafTypeDescriptorLength"`
+}
+
+// GetLimits returns the server's limits so that trees can
+// be checked before they're created. Trees over the limits
+// are rejected with [CodeBodyTooLarge], [CodeTooManyLeaves],
+// [CodeInvalidLeaf] or [CodeInvalidRequest].
+func (c *Client) GetLimits(ctx context.Context) (*Limits, error) {
+	resp := &Limits{}
+	err := c.sendRequest(ctx, http.MethodGet, "/limits", nil, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,27 @@
+package lanyard
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetLimits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/limits" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"maxBodyBytes": 1024, "maxLeaves": 10, "maxLeafBytes": 32, "maxLeafTypeDescriptorLength": 2}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	got, err := New(WithURL(srv.URL)).GetLimits(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Limits{MaxBodyBytes: 1024, MaxLeaves: 10, MaxLeafBytes: 32, MaxLtdLength: 2}
+	if *got != want {
+		t.Errorf("expected %+v, got %+v", want, *got)
+	}
+}
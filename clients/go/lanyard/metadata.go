@@ -0,0 +1,134 @@
+package lanyard
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// TreeMetadata describes a tree. It is set when the tree
+// is created using [Name], [Description], [Tags],
+// [Contract] and [ClaimWindow], and can't be changed.
+type TreeMetadata struct {
+	Name            string        `json:"name,omitempty"`
+	Description     string        `json:"description,omitempty"`
+	Tags            []string      `json:"tags,omitempty"`
+	ChainID         int64         `json:"chainId,omitempty"`
+	ContractAddress hexutil.Bytes `json:"contractAddress,omitempty"`
+
+	// ClaimStart and ClaimEnd bound when the
+	// tree's leaves can be claimed
+	ClaimStart *time.Time `json:"claimStart,omitempty"`
+	ClaimEnd   *time.Time `json:"claimEnd,omitempty"`
+}
+
+func (r *createTreeRequest) metadata() *TreeMetadata {
+	if r.Metadata == nil {
+		r.Metadata = &TreeMetadata{}
+	}
+	return r.Metadata
+}
+
+// Name sets the name of the tree.
+func Name(name string) TreeOpt {
+	return func(r *createTreeRequest) {
+		r.metadata().Name = name
+	}
+}
+
+// Description sets the description of the tree.
+func Description(desc string) TreeOpt {
+	return func(r *createTreeRequest) {
+		r.metadata().Description = desc
+	}
+}
+
+// Tags adds tags that the tree can be found by
+// with [Client.ListTrees].
+func Tags(tags ...string) TreeOpt {
+	return func(r *createTreeRequest) {
+		m := r.metadata()
+		m.Tags = append(m.Tags, tags...)
+	}
+}
+
+// Contract sets the contract that the tree's proofs
+// are claimed from and the id of its chain.
+func Contract(chainID int64, addr common.Address) TreeOpt {
+	return func(r *createTreeRequest) {
+		m := r.metadata()
+		m.ChainID = chainID
+		m.ContractAddress = addr.Bytes()
+	}
+}
+
+// ClaimWindow sets when the tree's leaves can be claimed.
+// A zero time leaves that end of the window open.
+func ClaimWindow(start, end time.Time) TreeOpt {
+	return func(r *createTreeRequest) {
+		m := r.metadata()
+		if !start.IsZero() {
+			m.ClaimStart = &start
+		}
+		if !end.IsZero() {
+			m.ClaimEnd = &end
+		}
+	}
+}
+
+// TreeFilter selects the trees returned by [Client.ListTrees].
+// Zero values match every tree.
+type TreeFilter struct {
+	// Tags only matches trees with every tag
+	Tags            []string
+	ContractAddress *common.Address
+	ChainID         int64
+
+	// Limit defaults to 100 and is at most 1000
+	Limit  int
+	Offset int
+}
+
+type TreeSummary struct {
+	MerkleRoot hexutil.Bytes `json:"merkleRoot"`
+	Metadata   TreeMetadata  `json:"metadata"`
+	InsertedAt time.Time     `json:"insertedAt"`
+}
+
+type ListTreesResponse struct {
+	Trees []TreeSummary `json:"trees"`
+}
+
+// ListTrees searches the metadata of public trees, returning
+// the most recently created trees first. Private trees and
+// trees created without metadata are never returned.
+func (c *Client) ListTrees(ctx context.Context, f TreeFilter) (*ListTreesResponse, error) {
+	q := url.Values{}
+	for _, t := range f.Tags {
+		q.Add("tag", t)
+	}
+	if f.ContractAddress != nil {
+		q.Set("contract", f.ContractAddress.Hex())
+	}
+	if f.ChainID != 0 {
+		q.Set("chainId", strconv.FormatInt(f.ChainID, 10))
+	}
+	if f.Limit != 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+	if f.Offset != 0 {
+		q.Set("offset", strconv.Itoa(f.Offset))
+	}
+
+	resp := &ListTreesResponse{}
+	err := c.sendRequest(ctx, http.MethodGet, "/trees?"+q.Encode(), nil, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
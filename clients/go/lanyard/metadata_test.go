@@ -0,0 +1,74 @@
+package lanyard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCreateTreeMetadata(t *testing.T) {
+	tree, err := BuildTreeLocal(testLeaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		got   createTreeRequest
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(CreateResponse{MerkleRoot: tree.Root()})
+	}))
+	t.Cleanup(srv.Close)
+
+	_, err = New(WithURL(srv.URL)).CreateTypedTree(context.Background(), testLeaves, []string{"address"}, true,
+		Name("allowlist"),
+		Tags("mint", "season-1"),
+		Contract(1, common.HexToAddress("0x01")),
+		ClaimWindow(start, time.Time{}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := got.Metadata
+	switch {
+	case m == nil:
+		t.Fatal("expected metadata to be sent")
+	case m.Name != "allowlist" || len(m.Tags) != 2 || m.ChainID != 1:
+		t.Errorf("unexpected metadata %+v", m)
+	case common.BytesToAddress(m.ContractAddress) != common.HexToAddress("0x01"):
+		t.Errorf("unexpected contract %s", m.ContractAddress)
+	case m.ClaimStart == nil || !m.ClaimStart.Equal(start) || m.ClaimEnd != nil:
+		t.Errorf("unexpected claim window %v %v", m.ClaimStart, m.ClaimEnd)
+	}
+}
+
+func TestListTrees(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/trees" || len(q["tag"]) != 2 || q.Get("contract") == "" || q.Get("limit") != "10" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"trees": [{"merkleRoot": "0x01", "metadata": {"name": "allowlist"}, "insertedAt": "2026-01-01T00:00:00Z"}]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	addr := common.HexToAddress("0x01")
+	resp, err := New(WithURL(srv.URL)).ListTrees(context.Background(), TreeFilter{
+		Tags:            []string{"mint", "season-1"},
+		ContractAddress: &addr,
+		Limit:           10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Trees) != 1 || resp.Trees[0].Metadata.Name != "allowlist" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
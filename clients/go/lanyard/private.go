@@ -0,0 +1,74 @@
+package lanyard
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
+// A TreeOpt sets optional parameters of a tree
+// created with [Client.CreateTree] or [Client.CreateTypedTree].
+type TreeOpt func(*createTreeRequest)
+
+// Private creates a private tree. The leaves of a private tree
+// can't be listed with [Client.GetTreeFromRoot] or searched with
+// [Client.GetProofFromAddr] without the read token returned in
+// [CreateResponse.ReadToken] or the API key that created it.
+// [Client.GetProofFromLeaf] works for anyone holding a leaf.
+// Its root is never returned by [Client.GetRootsFromProof].
+//
+// Creating a private tree that already exists returns
+// [ErrTreeExists], since its read token can't be recovered.
+//
+// The client remembers the read tokens of the trees it
+// creates. Use [WithReadToken] to read other private trees.
+func Private() TreeOpt {
+	return func(r *createTreeRequest) {
+		r.Visibility = VisibilityPrivate
+	}
+}
+
+// WithReadToken sends token with requests for the private
+// tree with root. See [Private].
+func WithReadToken(root hexutil.Bytes, token string) ClientOpt {
+	return func(c *Client) {
+		c.readTokens.set(root, token)
+	}
+}
+
+// readTokens holds the read tokens of private trees by root.
+type readTokens struct {
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func (rt *readTokens) set(root hexutil.Bytes, token string) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.tokens == nil {
+		rt.tokens = map[string]string{}
+	}
+	rt.tokens[root.String()] = token
+}
+
+// Returns the token for the root in the query of path, if any.
+func (rt *readTokens) forPath(path string) string {
+	u, err := url.Parse(path)
+	if err != nil {
+		return ""
+	}
+	root := strings.ToLower(u.Query().Get("root"))
+	if root == "" {
+		return ""
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.tokens[root]
+}
@@ -0,0 +1,89 @@
+package lanyard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestPrivateTreeReadToken(t *testing.T) {
+	tree, err := BuildTreeLocal(testLeaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodPost:
+			var req createTreeRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Visibility != VisibilityPrivate {
+				t.Errorf("expected a private tree, got %q", req.Visibility)
+			}
+			json.NewEncoder(w).Encode(CreateResponse{MerkleRoot: tree.Root(), ReadToken: "token"})
+		case http.MethodGet:
+			if r.Header.Get("X-Read-Token") != "token" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"error": true, "code": "PRIVATE_TREE", "message": "tree is private"}`))
+				return
+			}
+			json.NewEncoder(w).Encode(TreeResponse{UnhashedLeaves: testLeaves})
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx := context.Background()
+	_, err = New(WithURL(srv.URL)).GetTreeFromRoot(ctx, tree.Root())
+	if !errors.Is(err, ErrPrivateTree) {
+		t.Fatalf("expected ErrPrivateTree without a token, got %v", err)
+	}
+
+	c := New(WithURL(srv.URL))
+	resp, err := c.CreateTree(ctx, testLeaves, Private())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ReadToken != "token" {
+		t.Errorf("expected read token, got %q", resp.ReadToken)
+	}
+	if _, err := c.GetTreeFromRoot(ctx, tree.Root()); err != nil {
+		t.Errorf("expected the creating client to remember the token, got %v", err)
+	}
+
+	c = New(WithURL(srv.URL), WithReadToken(hexutil.Bytes(tree.Root()), "token"))
+	if _, err := c.GetTreeFromRoot(ctx, tree.Root()); err != nil {
+		t.Errorf("expected the token to be sent, got %v", err)
+	}
+}
+
+func TestPrivateTreeExists(t *testing.T) {
+	tree, err := BuildTreeLocal(testLeaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var public bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if public {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error": true, "code": "TREE_EXISTS", "message": "tree already exists with a different visibility", "details": {"visibility": "public"}}`))
+			return
+		}
+		// an existing private tree is returned without its token
+		json.NewEncoder(w).Encode(CreateResponse{MerkleRoot: tree.Root()})
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(WithURL(srv.URL))
+	for _, public = range []bool{false, true} {
+		_, err := c.CreateTree(context.Background(), testLeaves, Private())
+		if !errors.Is(err, ErrTreeExists) {
+			t.Errorf("public %t: expected ErrTreeExists, got %v", public, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package lanyard
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Parses the Retry-After header, which is
+// either a number of seconds or an http date.
+func parseRetryAfter(h string, now time.Time) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(h); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+// WithRetry sets how many times a failed request is retried
+// and the bounds of the exponential backoff between attempts.
+// Network errors and 5xx responses are only retried for
+// idempotent requests. 429 responses are retried for every
+// request and honor the Retry-After header. Use a
+// maxRetries of 0 to disable retries.
+func WithRetry(maxRetries int, baseDelay, maxDelay time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.retry = retryPolicy{
+			maxRetries: maxRetries,
+			baseDelay:  baseDelay,
+			maxDelay:   maxDelay,
+		}
+	}
+}
+
+// Returns how long to wait before retrying a request
+// that failed with err, or false if it shouldn't be retried.
+func (p retryPolicy) backoff(attempt int, idempotent bool, err error) (time.Duration, bool) {
+	if attempt >= p.maxRetries {
+		return 0, false
+	}
+
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests:
+		if apiErr.RetryAfter > 0 {
+			return apiErr.RetryAfter, true
+		}
+	case !idempotent:
+		return 0, false
+	case errors.As(err, &apiErr):
+		if apiErr.StatusCode < 500 {
+			return 0, false
+		}
+		if apiErr.RetryAfter > 0 {
+			return apiErr.RetryAfter, true
+		}
+	case errors.Is(err, errDecode):
+		return 0, false
+	}
+
+	// exponential backoff with full jitter
+	d := p.baseDelay << attempt
+	if d > p.maxDelay || d <= 0 {
+		d = p.maxDelay
+	}
+	if d <= 0 {
+		return 0, true
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1)), true
+}
+
+// Reports whether repeating a request with the method has
+// the same effect as making it once, per RFC 9110.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
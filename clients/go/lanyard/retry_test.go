@@ -0,0 +1,113 @@
+package lanyard
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Returns a client for a server that responds with each
+// status in turn and then 200 for every later request.
+func retryClient(t *testing.T, header http.Header, statuses ...int) (*Client, *atomic.Int32) {
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(n.Add(1)) - 1
+		w.Header().Set("Content-Type", "application/json")
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		if i < len(statuses) {
+			w.WriteHeader(statuses[i])
+			w.Write([]byte(`{"error": true, "message": "nope"}`))
+			return
+		}
+		w.Write([]byte(`{"roots": []}`))
+	}))
+	t.Cleanup(srv.Close)
+	return New(WithURL(srv.URL), WithRetry(3, time.Millisecond, 10*time.Millisecond)), &n
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	c, n := retryClient(t, nil, http.StatusServiceUnavailable, http.StatusBadGateway)
+	err := c.sendRequest(context.Background(), http.MethodGet, "/roots", nil, &RootsResponse{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Load() != 3 {
+		t.Errorf("expected 3 requests, got %d", n.Load())
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	c, n := retryClient(t, nil, 500, 500, 500, 500, 500)
+	err := c.sendRequest(context.Background(), http.MethodGet, "/roots", nil, &RootsResponse{})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 500 || apiErr.Message != "nope" {
+		t.Fatalf("expected APIError with status 500 and server message, got %v", err)
+	}
+	if n.Load() != 4 {
+		t.Errorf("expected 4 requests, got %d", n.Load())
+	}
+}
+
+func TestNoRetryNonIdempotent(t *testing.T) {
+	c, n := retryClient(t, nil, http.StatusServiceUnavailable)
+	err := c.sendRequest(context.Background(), http.MethodPost, "/roots", nil, &RootsResponse{})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if n.Load() != 1 {
+		t.Errorf("expected 1 request, got %d", n.Load())
+	}
+}
+
+func TestNoRetryClientError(t *testing.T) {
+	c, n := retryClient(t, nil, http.StatusNotFound)
+	err := c.sendRequest(context.Background(), http.MethodGet, "/roots", nil, &RootsResponse{})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if n.Load() != 1 {
+		t.Errorf("expected 1 request, got %d", n.Load())
+	}
+}
+
+func TestRetryTooManyRequests(t *testing.T) {
+	c, n := retryClient(t, http.Header{"Retry-After": {"1"}}, http.StatusTooManyRequests)
+	start := time.Now()
+	err := c.sendRequest(context.Background(), http.MethodPost, "/roots", nil, &RootsResponse{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Load() != 2 {
+		t.Errorf("expected 2 requests, got %d", n.Load())
+	}
+	if time.Since(start) < time.Second {
+		t.Error("expected Retry-After to be honored")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		h    string
+		want time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-1", 0},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+	for _, c := range cases {
+		if got := parseRetryAfter(c.h, now); got != c.want {
+			t.Errorf("%q: expected %s got %s", c.h, c.want, got)
+		}
+	}
+}
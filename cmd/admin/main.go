@@ -0,0 +1,95 @@
+// Command admin manages the API keys used by
+// the api server when it requires keys for writes.
+//
+//	admin keys create -name partner [-scopes trees:write]
+//	admin keys list
+//	admin keys revoke -name partner
+//
+// It connects to the database in DATABASE_URL.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/contextwtf/lanyard/api"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "admin error: %s\n", err)
+		debug.PrintStack()
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: admin keys create -name <name> [-scopes trees:write]")
+	fmt.Fprintln(os.Stderr, "       admin keys list")
+	fmt.Fprintln(os.Stderr, "       admin keys revoke -name <name>")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 3 || os.Args[1] != "keys" {
+		usage()
+	}
+
+	ctx := context.Background()
+	const defaultPGURL = "postgres:///al"
+	dburl := os.Getenv("DATABASE_URL")
+	if dburl == "" {
+		dburl = defaultPGURL
+	}
+	db, err := pgxpool.Connect(ctx, dburl)
+	check(err)
+	defer db.Close()
+
+	var (
+		fs     = flag.NewFlagSet(os.Args[2], flag.ExitOnError)
+		name   = fs.String("name", "", "name of the key, recorded as the owner of its trees")
+		scopes = fs.String("scopes", api.ScopeTreesWrite, "comma separated scopes: "+strings.Join(api.Scopes, ", "))
+	)
+	check(fs.Parse(os.Args[3:]))
+
+	switch os.Args[2] {
+	case "create":
+		var sc []string
+		if *scopes != "" {
+			sc = strings.Split(*scopes, ",")
+		}
+		key, err := api.CreateAPIKey(ctx, db, *name, sc)
+		check(err)
+		fmt.Println(key)
+		fmt.Fprintln(os.Stderr, "store the key now, it can't be shown again")
+	case "list":
+		keys, err := api.ListAPIKeys(ctx, db)
+		check(err)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSCOPES\tCREATED\tREVOKED")
+		for _, k := range keys {
+			revoked := "-"
+			if k.RevokedAt != nil {
+				revoked = k.RevokedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				k.Name,
+				strings.Join(k.Scopes, ","),
+				k.InsertedAt.Format(time.RFC3339),
+				revoked,
+			)
+		}
+		check(w.Flush())
+	case "revoke":
+		check(api.RevokeAPIKey(ctx, db, *name))
+	default:
+		usage()
+	}
+}
@@ -3,30 +3,38 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"runtime/debug"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/contextwtf/lanyard/api"
 	"github.com/contextwtf/lanyard/api/migrations"
 	"github.com/contextwtf/lanyard/api/tracing"
 	"github.com/contextwtf/migrate"
-	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	_ "github.com/jackc/pgx/v4/stdlib"
-	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/profile"
+	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/opentracer"
-	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"google.golang.org/grpc"
+	ddtracer "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 )
 
 var GitSha string
 
+// How long in-flight requests and unsent spans
+// are waited for when the server is stopped.
+const shutdownTimeout = 5 * time.Second
+
 func check(err error) {
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "processor error: %s", err)
@@ -35,13 +43,144 @@ func check(err error) {
 	}
 }
 
+// Returns the duration value of the environment
+// variable key, or def if it isn't set.
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	check(err)
+	return d
+}
+
+// Returns the integer value of the environment variable
+// key, or def if it isn't set.
+func envInt(key string, def int64) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	check(err)
+	return n
+}
+
 func main() {
 	env := os.Getenv("ENV")
 	if env == "" {
 		env = "dev"
 	}
 
-	shouldProfile := flag.Bool("profile", false, "enable profiling")
+	var (
+		shouldProfile = flag.Bool("profile", false, "enable profiling")
+		cacheBytes    = flag.Int64(
+			"tree-cache-bytes",
+			envInt("TREE_CACHE_BYTES", api.DefaultTreeCacheBytes),
+			"max bytes of leaves and levels held in the tree cache (0 for no limit)",
+		)
+		cacheEntries = flag.Int64(
+			"tree-cache-entries",
+			envInt("TREE_CACHE_ENTRIES", api.DefaultTreeCacheEntries),
+			"max number of trees held in the tree cache (0 for no limit)",
+		)
+		treeCheck = flag.Duration(
+			"tree-check-interval",
+			envDuration("TREE_CHECK_INTERVAL", api.DefaultTreeCheck),
+			"how often a cached tree is checked for deletion by other servers (0 to disable)",
+		)
+		requireKeys = flag.Bool(
+			"require-api-keys",
+			os.Getenv("REQUIRE_API_KEYS") == "true",
+			"require an api key to create trees (see cmd/admin)",
+		)
+		metricsListen = flag.String(
+			"metrics-listen",
+			os.Getenv("METRICS_LISTEN"),
+			"address serving /metrics without the admin token (empty to disable)",
+		)
+		grpcListen = flag.String(
+			"grpc-listen",
+			os.Getenv("GRPC_LISTEN"),
+			"address of the grpc server (empty to disable)",
+		)
+		gcInterval = flag.Duration(
+			"gc-interval",
+			envDuration("GC_INTERVAL", time.Minute),
+			"how often expired trees are deleted (0 to disable)",
+		)
+		gcBatchSize = flag.Int64(
+			"gc-batch-size",
+			envInt("GC_BATCH_SIZE", 100),
+			"max number of expired trees deleted per transaction",
+		)
+		writeRate = flag.Int64(
+			"write-rate-limit",
+			envInt("WRITE_RATE_LIMIT", 0),
+			"trees each api key or ip can create per minute (0 for no limit)",
+		)
+		writeBurst = flag.Int64(
+			"write-burst",
+			envInt("WRITE_BURST", 10),
+			"trees each api key or ip can create at once",
+		)
+		readRate = flag.Int64(
+			"read-rate-limit",
+			envInt("READ_RATE_LIMIT", 0),
+			"read requests each api key or ip can make per minute (0 for no limit)",
+		)
+		readBurst = flag.Int64(
+			"read-burst",
+			envInt("READ_BURST", 100),
+			"read requests each api key or ip can make at once",
+		)
+		redisURL = flag.String(
+			"rate-limit-redis-url",
+			os.Getenv("RATE_LIMIT_REDIS_URL"),
+			"redis url for rate limits shared between servers (empty to limit each server separately)",
+		)
+		maxBodyBytes = flag.Int64(
+			"max-body-bytes",
+			envInt("MAX_BODY_BYTES", api.DefaultLimits.MaxBodyBytes),
+			"max bytes in a create or verify request (0 for no limit)",
+		)
+		maxLeaves = flag.Int64(
+			"max-leaves",
+			envInt("MAX_LEAVES", int64(api.DefaultLimits.MaxLeaves)),
+			"max leaves in a tree (0 for no limit)",
+		)
+		maxLeafBytes = flag.Int64(
+			"max-leaf-bytes",
+			envInt("MAX_LEAF_BYTES", int64(api.DefaultLimits.MaxLeafBytes)),
+			"max bytes in a leaf (0 for no limit)",
+		)
+		maxLtdLength = flag.Int64(
+			"max-ltd-length",
+			envInt("MAX_LTD_LENGTH", int64(api.DefaultLimits.MaxLtdLength)),
+			"max types in a leaf type descriptor (0 for no limit)",
+		)
+		trustedProxies = flag.String(
+			"trusted-proxies",
+			os.Getenv("TRUSTED_PROXIES"),
+			"comma separated cidrs of proxies whose client ip headers are trusted",
+		)
+		proxyHeaders = flag.String(
+			"trusted-proxy-headers",
+			os.Getenv("TRUSTED_PROXY_HEADERS"),
+			"comma separated headers read from trusted proxies, in order of precedence (default Forwarded,X-Forwarded-For)",
+		)
+		tracer = flag.String(
+			"tracer",
+			os.Getenv("TRACER"),
+			"datadog or otlp (default datadog if DD_AGENT_HOST is set, otherwise none)",
+		)
+		otlpProtocol = flag.String(
+			"otlp-protocol",
+			os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"),
+			"grpc or http/protobuf (default http/protobuf)",
+		)
+	)
 	flag.Parse()
 
 	if *shouldProfile {
@@ -50,15 +189,33 @@ func main() {
 	}
 
 	ddAgent := os.Getenv("DD_AGENT_HOST")
-	if ddAgent != "" {
-		t := opentracer.New(
-			tracer.WithEnv(os.Getenv("DD_ENV")),
-			tracer.WithService(os.Getenv("DD_SERVICE")),
-			tracer.WithServiceVersion(GitSha),
-			tracer.WithAgentAddr(net.JoinHostPort(ddAgent, "8126")),
-		)
-		opentracing.SetGlobalTracer(t)
+	if *tracer == "" && ddAgent != "" {
+		*tracer = "datadog"
+	}
+	switch *tracer {
+	case "":
+	case "datadog":
+		tracing.SetTracer(tracing.NewDatadog(
+			ddtracer.WithEnv(os.Getenv("DD_ENV")),
+			ddtracer.WithService(os.Getenv("DD_SERVICE")),
+			ddtracer.WithServiceVersion(GitSha),
+			ddtracer.WithAgentAddr(net.JoinHostPort(ddAgent, "8126")),
+		))
+	case "otlp":
+		t, err := tracing.NewOTLP(context.Background(), *otlpProtocol, GitSha)
+		check(err)
+		tracing.SetTracer(t)
+	default:
+		check(fmt.Errorf("unknown tracer %q", *tracer))
 	}
+	defer func() {
+		// flush the spans of the last requests
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := tracing.Shutdown(ctx); err != nil {
+			log.Err(err).Msg("shutting down tracer")
+		}
+	}()
 
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	if env == "dev" {
@@ -69,6 +226,8 @@ func main() {
 		logger = log.Logger.With().Caller().Logger()
 		ctx    = logger.WithContext(context.Background())
 	)
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	const defaultPGURL = "postgres:///al"
 	dburl := os.Getenv("DATABASE_URL")
 	if dburl == "" {
@@ -77,14 +236,6 @@ func main() {
 	dbc, err := pgxpool.ParseConfig(dburl)
 	check(err)
 
-	if ddAgent != "" {
-		// trace db queries if tracing is enabled
-		dbc.ConnConfig.Logger = tracing.NewDBTracer(
-			dbc.ConnConfig.Host,
-		)
-		dbc.ConnConfig.LogLevel = pgx.LogLevelTrace
-	}
-
 	dbc.MaxConns = 30
 	db, err := pgxpool.ConnectConfig(ctx, dbc)
 	check(err)
@@ -98,17 +249,102 @@ func main() {
 	check(migrate.Run(ctx, mdb, migrations.Migrations))
 	check(mdb.Close())
 
-	s := api.New(db)
+	opts := []api.Option{
+		api.WithTreeCache(*cacheBytes, int(*cacheEntries)),
+		api.WithTreeCheckInterval(*treeCheck),
+		api.WithAdminToken(os.Getenv("ADMIN_TOKEN")),
+		api.WithLimits(api.Limits{
+			MaxBodyBytes: *maxBodyBytes,
+			MaxLeaves:    int(*maxLeaves),
+			MaxLeafBytes: int(*maxLeafBytes),
+			MaxLtdLength: int(*maxLtdLength),
+		}),
+	}
+	if *requireKeys {
+		opts = append(opts, api.WithAPIKeys())
+	}
+	tp, err := api.ParseTrustedProxies(*trustedProxies, *proxyHeaders)
+	check(err)
+	opts = append(opts, api.WithTrustedProxies(tp))
+	opts = append(opts, api.WithRateLimits(
+		api.PerMinute(float64(*writeRate), int(*writeBurst)),
+		api.PerMinute(float64(*readRate), int(*readBurst)),
+	))
+	if *redisURL != "" {
+		ropts, err := redis.ParseURL(*redisURL)
+		check(err)
+		opts = append(opts, api.WithRateLimiter(
+			api.NewRedisRateLimiter(redis.NewClient(ropts), "lanyard:ratelimit:"),
+		))
+	}
+	s := api.New(db, opts...)
+
+	if *gcInterval > 0 {
+		if *gcBatchSize < 1 {
+			check(fmt.Errorf("invalid gc-batch-size %d: %w", *gcBatchSize, api.ErrBatchSize))
+		}
+		go s.RunGC(ctx, *gcInterval, int(*gcBatchSize))
+	}
 
 	const defaultListen = ":8080"
 	listen := os.Getenv("LISTEN")
 	if listen == "" {
 		listen = defaultListen
 	}
+	var gs *grpc.Server
+	if *grpcListen != "" {
+		lis, err := net.Listen("tcp", *grpcListen)
+		check(err)
+		gs = s.GRPCServer()
+		go func() {
+			log.Ctx(ctx).Info().Str("listen", *grpcListen).Msg("grpc server")
+			check(gs.Serve(lis))
+		}()
+	}
+
+	if *metricsListen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", s.MetricsHandler())
+		go func() {
+			log.Ctx(ctx).Info().Str("listen", *metricsListen).Msg("metrics server")
+			check(http.ListenAndServe(*metricsListen, mux))
+		}()
+	}
+
 	hs := &http.Server{
 		Addr:    listen,
 		Handler: s.Handler(env, GitSha),
 	}
 	log.Ctx(ctx).Info().Str("listen", listen).Str("git-sha", GitSha).Msg("http server")
-	check(hs.ListenAndServe())
+	go func() {
+		if err := hs.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			check(err)
+		}
+	}()
+
+	// return on a signal, after the requests in flight,
+	// so that the deferred shutdowns run
+	<-ctx.Done()
+	log.Ctx(ctx).Info().Msg("shutting down")
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if gs != nil {
+		// GracefulStop has no timeout, so it's
+		// cut short by Stop after shutdownTimeout
+		stopped := make(chan struct{})
+		go func() {
+			gs.GracefulStop()
+			close(stopped)
+		}()
+		defer func() {
+			select {
+			case <-stopped:
+			case <-sctx.Done():
+				gs.Stop()
+			}
+		}()
+	}
+	if err := hs.Shutdown(sctx); err != nil {
+		log.Ctx(ctx).Err(err).Msg("shutting down http server")
+	}
 }
@@ -0,0 +1,95 @@
+package merkle
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The version of the binary format written by [Tree.MarshalBinary].
+//
+// Version 1 is laid out as:
+//
+//	version   byte
+//	levels    uvarint
+//	for each level, starting with the leaves:
+//	    count uvarint
+//	    nodes count * 32 bytes
+const binaryVersion = 1
+
+const nodeSize = 32
+
+// MarshalBinary encodes every level of the tree so that it can
+// be persisted and loaded with [Tree.UnmarshalBinary] without
+// rehashing the leaves.
+func (t Tree) MarshalBinary() ([]byte, error) {
+	size := 1 + binary.MaxVarintLen64
+	for _, level := range t {
+		size += binary.MaxVarintLen64 + len(level)*nodeSize
+	}
+
+	b := make([]byte, 0, size)
+	b = append(b, binaryVersion)
+	b = binary.AppendUvarint(b, uint64(len(t)))
+	for _, level := range t {
+		b = binary.AppendUvarint(b, uint64(len(level)))
+		for _, h := range level {
+			if len(h) != nodeSize {
+				return nil, fmt.Errorf("node must be %d bytes, got %d", nodeSize, len(h))
+			}
+			b = append(b, h...)
+		}
+	}
+	return b, nil
+}
+
+var errMalformed = errors.New("malformed tree")
+
+// UnmarshalBinary decodes a tree encoded by [Tree.MarshalBinary].
+// The shape of the levels is checked but nodes are not rehashed.
+func (t *Tree) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return errMalformed
+	}
+	if data[0] != binaryVersion {
+		return fmt.Errorf("unsupported tree version %d", data[0])
+	}
+	// copy once so that the nodes can share a
+	// backing array that isn't owned by the caller
+	data = append([]byte(nil), data[1:]...)
+
+	nlevels, n := binary.Uvarint(data)
+	if n <= 0 || nlevels == 0 || nlevels > 64 {
+		return errMalformed
+	}
+	data = data[n:]
+
+	tr := make(Tree, 0, nlevels)
+	for i := uint64(0); i < nlevels; i++ {
+		count, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errMalformed
+		}
+		data = data[n:]
+		if count > uint64(len(data)/nodeSize) {
+			return errMalformed
+		}
+
+		if i > 0 && count != uint64(len(tr[i-1])+1)/2 {
+			return errMalformed
+		}
+
+		level := make([][]byte, count)
+		for j := range level {
+			level[j] = data[:nodeSize:nodeSize]
+			data = data[nodeSize:]
+		}
+		tr = append(tr, level)
+	}
+	if len(data) != 0 || len(tr[len(tr)-1]) != 1 {
+		return errMalformed
+	}
+
+	*t = tr
+	return nil
+}
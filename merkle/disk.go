@@ -0,0 +1,195 @@
+package merkle
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// A DiskTree is a Tree whose levels are stored in temporary
+// files instead of memory. It is useful for trees with
+// more leaves than can be held in memory. A DiskTree
+// has the same root and proofs as the Tree returned by [New]
+// for the same leaves.
+//
+// Call [DiskTree.Close] to remove the files.
+type DiskTree struct {
+	dir    string
+	levels []*os.File
+	counts []int
+	root   []byte
+}
+
+// Builds a DiskTree from newline separated hex encoded leaves
+// read from r. Blank lines are skipped and the 0x prefix is optional.
+// The level files are created in a new directory inside dir,
+// or inside [os.TempDir] if dir is empty.
+func NewDiskTree(r io.Reader, dir string) (*DiskTree, error) {
+	tmp, err := os.MkdirTemp(dir, "merkle-")
+	if err != nil {
+		return nil, err
+	}
+	t := &DiskTree{dir: tmp}
+
+	err = t.writeLeaves(r)
+	for err == nil && t.counts[len(t.counts)-1] > 1 {
+		err = t.writeLevel()
+	}
+	if err == nil {
+		t.root, err = t.node(len(t.levels)-1, 0)
+	}
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *DiskTree) create() (*os.File, error) {
+	f, err := os.Create(filepath.Join(t.dir, fmt.Sprintf("level-%d", len(t.levels))))
+	if err != nil {
+		return nil, err
+	}
+	t.levels = append(t.levels, f)
+	return f, nil
+}
+
+func (t *DiskTree) writeLeaves(r io.Reader) error {
+	f, err := t.create()
+	if err != nil {
+		return err
+	}
+
+	var (
+		w     = bufio.NewWriter(f)
+		s     = bufio.NewScanner(r)
+		count int
+	)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := bytes.TrimSpace(s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		leaf, err := decodeHexLeaf(line)
+		if err != nil {
+			return fmt.Errorf("leaf %d: %w", count, err)
+		}
+		if _, err := w.Write(crypto.Keccak256(leaf)); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("no leaves provided")
+	}
+	t.counts = append(t.counts, count)
+	return w.Flush()
+}
+
+func decodeHexLeaf(b []byte) ([]byte, error) {
+	b = bytes.TrimPrefix(bytes.TrimPrefix(b, []byte("0x")), []byte("0X"))
+	if len(b)%2 == 1 {
+		b = append([]byte{'0'}, b...)
+	}
+	leaf := make([]byte, hex.DecodedLen(len(b)))
+	_, err := hex.Decode(leaf, b)
+	return leaf, err
+}
+
+// Reads the last level sequentially and writes
+// the merged pairs to a new level. See [hashMerge].
+func (t *DiskTree) writeLevel() error {
+	var (
+		prev  = t.levels[len(t.levels)-1]
+		count = t.counts[len(t.counts)-1]
+	)
+	if _, err := prev.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	f, err := t.create()
+	if err != nil {
+		return err
+	}
+
+	var (
+		r    = bufio.NewReader(prev)
+		w    = bufio.NewWriter(f)
+		a, b = make([]byte, nodeSize), make([]byte, nodeSize)
+	)
+	for i := 0; i < count; i += 2 {
+		if _, err := io.ReadFull(r, a); err != nil {
+			return err
+		}
+		node := a
+		if i+1 < count {
+			if _, err := io.ReadFull(r, b); err != nil {
+				return err
+			}
+			node = hashPair(a, b)
+		}
+		if _, err := w.Write(node); err != nil {
+			return err
+		}
+	}
+	t.counts = append(t.counts, (count+1)/2)
+	return w.Flush()
+}
+
+// Returns the number of leaves in the tree.
+func (t *DiskTree) Len() int {
+	return t.counts[0]
+}
+
+func (t *DiskTree) Root() []byte {
+	return t.root
+}
+
+func (t *DiskTree) node(level, index int) ([]byte, error) {
+	h := make([]byte, nodeSize)
+	_, err := t.levels[level].ReadAt(h, int64(index)*nodeSize)
+	return h, err
+}
+
+// Returns the proof for the leaf at index by reading
+// the sibling at each level from the level files.
+// See [Tree.Proof] for details.
+func (t *DiskTree) Proof(index int) ([][]byte, error) {
+	if index < 0 || index >= t.Len() {
+		return nil, fmt.Errorf("index %d out of range", index)
+	}
+	var proof [][]byte
+	for level, count := range t.counts {
+		i := index + 1
+		if index%2 == 1 {
+			i = index - 1
+		}
+		if i < count {
+			h, err := t.node(level, i)
+			if err != nil {
+				return nil, err
+			}
+			proof = append(proof, h)
+		}
+		index = index / 2
+	}
+	return proof, nil
+}
+
+// Closes and removes the level files.
+func (t *DiskTree) Close() error {
+	for _, f := range t.levels {
+		f.Close()
+	}
+	return os.RemoveAll(t.dir)
+}
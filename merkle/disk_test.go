@@ -0,0 +1,76 @@
+package merkle
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDiskTree(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 5, 8, 33} {
+		var (
+			leaves [][]byte
+			input  strings.Builder
+		)
+		for i := 0; i < n; i++ {
+			l := []byte{byte(i), byte(i + 1)}
+			leaves = append(leaves, l)
+			fmt.Fprintf(&input, "0x%s\n", common.Bytes2Hex(l))
+		}
+		input.WriteString("\n")
+
+		dt, err := NewDiskTree(strings.NewReader(input.String()), t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		mt := New(leaves)
+		if !bytes.Equal(dt.Root(), mt.Root()) {
+			t.Errorf("n=%d: got root %x want %x", n, dt.Root(), mt.Root())
+		}
+		if dt.Len() != n {
+			t.Errorf("n=%d: got %d leaves", n, dt.Len())
+		}
+
+		for i, l := range leaves {
+			pf, err := dt.Proof(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := mt.Proof(i)
+			if len(pf) != len(want) {
+				t.Fatalf("n=%d i=%d: got %d proof nodes want %d", n, i, len(pf), len(want))
+			}
+			for j := range want {
+				if !bytes.Equal(pf[j], want[j]) {
+					t.Errorf("n=%d i=%d: proof node %d differs", n, i, j)
+				}
+			}
+			if !Valid(dt.Root(), pf, l) {
+				t.Errorf("n=%d i=%d: invalid proof", n, i)
+			}
+		}
+		if _, err := dt.Proof(n); err == nil {
+			t.Error("expected error for out of range index")
+		}
+
+		dir := dt.dir
+		if err := dt.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", dir)
+		}
+	}
+}
+
+func TestDiskTreeErrors(t *testing.T) {
+	for _, input := range []string{"", "\n\n", "0x01\nzz\n"} {
+		if _, err := NewDiskTree(strings.NewReader(input), t.TempDir()); err == nil {
+			t.Errorf("expected error for input %q", input)
+		}
+	}
+}
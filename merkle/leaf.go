@@ -0,0 +1,145 @@
+package merkle
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// Encodes values as an unhashed leaf using the abi types
+// described by ltd, e.g. ["address", "uint256"]. When packed is
+// true the values are encoded like solidity's abi.encodePacked,
+// otherwise like abi.encode.
+//
+// Addresses, bytes and fixed bytes are hex encoded.
+// Integers may be decimal or 0x prefixed hex.
+// Bools are "true" or "false".
+func EncodeLeaf(ltd, values []string, packed bool) ([]byte, error) {
+	if len(ltd) != len(values) {
+		return nil, fmt.Errorf("expected %d values, got %d", len(ltd), len(values))
+	}
+
+	var (
+		args abi.Arguments
+		vals []any
+	)
+	for i, desc := range ltd {
+		t, err := abi.NewType(desc, "", nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid type %q: %w", desc, err)
+		}
+		v, err := parseValue(t, values[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %q: %w", desc, values[i], err)
+		}
+		args = append(args, abi.Argument{Type: t})
+		vals = append(vals, v)
+	}
+
+	if !packed {
+		return args.Pack(vals...)
+	}
+
+	var leaf []byte
+	for i, arg := range args {
+		b, err := encodePacked(arg.Type, vals[i])
+		if err != nil {
+			return nil, err
+		}
+		leaf = append(leaf, b...)
+	}
+	return leaf, nil
+}
+
+// Returns s as the go type that the abi package uses for t.
+func parseValue(t abi.Type, s string) (any, error) {
+	switch t.T {
+	case abi.AddressTy:
+		if !common.IsHexAddress(s) {
+			return nil, fmt.Errorf("not an address")
+		}
+		return common.HexToAddress(s), nil
+	case abi.BoolTy:
+		return strconv.ParseBool(s)
+	case abi.StringTy:
+		return s, nil
+	case abi.BytesTy:
+		return common.FromHex(s), nil
+	case abi.FixedBytesTy:
+		b := common.FromHex(s)
+		if len(b) != t.Size {
+			return nil, fmt.Errorf("expected %d bytes, got %d", t.Size, len(b))
+		}
+		v := reflect.New(t.GetType()).Elem()
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v.Interface(), nil
+	case abi.IntTy, abi.UintTy:
+		n, ok := new(big.Int).SetString(s, 0)
+		if !ok {
+			return nil, fmt.Errorf("not an integer")
+		}
+		if !fits(t, n) {
+			return nil, fmt.Errorf("value out of range")
+		}
+		rt := t.GetType()
+		if rt == reflect.TypeOf(n) {
+			return n, nil
+		}
+		if t.T == abi.IntTy {
+			return reflect.ValueOf(n.Int64()).Convert(rt).Interface(), nil
+		}
+		return reflect.ValueOf(n.Uint64()).Convert(rt).Interface(), nil
+	default:
+		return nil, fmt.Errorf("unsupported type")
+	}
+}
+
+func fits(t abi.Type, n *big.Int) bool {
+	if t.T == abi.UintTy {
+		return n.Sign() >= 0 && n.BitLen() <= t.Size
+	}
+	// -2^(size-1) <= n < 2^(size-1)
+	lim := new(big.Int).Lsh(big.NewInt(1), uint(t.Size-1))
+	return n.Cmp(lim) < 0 && n.Cmp(new(big.Int).Neg(lim)) >= 0
+}
+
+func encodePacked(t abi.Type, v any) ([]byte, error) {
+	switch t.T {
+	case abi.AddressTy:
+		return v.(common.Address).Bytes(), nil
+	case abi.BoolTy:
+		if v.(bool) {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case abi.StringTy:
+		return []byte(v.(string)), nil
+	case abi.BytesTy:
+		return v.([]byte), nil
+	case abi.FixedBytesTy:
+		rv := reflect.ValueOf(v)
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return b, nil
+	case abi.IntTy, abi.UintTy:
+		var n *big.Int
+		switch rv := reflect.ValueOf(v); rv.Kind() {
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = big.NewInt(rv.Int())
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = new(big.Int).SetUint64(rv.Uint())
+		default:
+			n = v.(*big.Int)
+		}
+		// two's complement for negative ints, truncated to the type's size
+		b := math.U256Bytes(new(big.Int).Set(n))
+		return b[len(b)-t.Size/8:], nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
@@ -0,0 +1,82 @@
+package merkle
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestEncodeLeaf(t *testing.T) {
+	cases := []struct {
+		ltd    []string
+		values []string
+		packed bool
+		want   []byte
+	}{
+		{
+			[]string{"address", "uint256"},
+			[]string{"0x0000000000000000000000000000000000000001", "10000000000000000000"},
+			true,
+			common.FromHex("0x00000000000000000000000000000000000000010000000000000000000000000000000000000000000000008ac7230489e80000"),
+		},
+		{
+			[]string{"address", "uint256"},
+			[]string{"0x0000000000000000000000000000000000000001", "0x8ac7230489e80000"},
+			false,
+			common.FromHex("0x00000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000008ac7230489e80000"),
+		},
+		{
+			[]string{"uint32", "address"},
+			[]string{"1", "0x0000000000000000000000000000000000000002"},
+			true,
+			common.FromHex("0x000000010000000000000000000000000000000000000002"),
+		},
+		{
+			[]string{"int8", "bool", "bytes2", "string"},
+			[]string{"-1", "true", "0xabcd", "hi"},
+			true,
+			common.FromHex("0xff01abcd6869"),
+		},
+		{
+			[]string{"uint8", "bool"},
+			[]string{"255", "false"},
+			false,
+			common.FromHex("0x00000000000000000000000000000000000000000000000000000000000000ff0000000000000000000000000000000000000000000000000000000000000000"),
+		},
+	}
+
+	for _, c := range cases {
+		got, err := EncodeLeaf(c.ltd, c.values, c.packed)
+		if err != nil {
+			t.Errorf("%v %v: %s", c.ltd, c.values, err)
+			continue
+		}
+		if !bytes.Equal(got, c.want) {
+			t.Errorf("%v %v: expected %x got %x", c.ltd, c.values, c.want, got)
+		}
+	}
+}
+
+func TestEncodeLeafErrors(t *testing.T) {
+	cases := []struct {
+		ltd    []string
+		values []string
+	}{
+		{[]string{"address"}, []string{}},
+		{[]string{"address"}, []string{"0x01"}},
+		{[]string{"uint8"}, []string{"256"}},
+		{[]string{"uint8"}, []string{"-1"}},
+		{[]string{"int8"}, []string{"128"}},
+		{[]string{"int8"}, []string{"-129"}},
+		{[]string{"bytes2"}, []string{"0x01"}},
+		{[]string{"bool"}, []string{"yes"}},
+		{[]string{"nope"}, []string{"1"}},
+	}
+
+	for _, c := range cases {
+		if _, err := EncodeLeaf(c.ltd, c.values, true); err == nil {
+			t.Errorf("%v %v: expected error", c.ltd, c.values)
+		}
+	}
+}
@@ -12,6 +12,8 @@ package merkle
 
 import (
 	"bytes"
+	"runtime"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/crypto"
 )
@@ -40,6 +42,66 @@ func New(items [][]byte) Tree {
 	return t
 }
 
+// Returns the same Tree as [New] but hashes the leaves
+// and each level concurrently across GOMAXPROCS workers.
+// Useful for large sets of items.
+func NewParallel(items [][]byte) Tree {
+	leaves := make([][]byte, len(items))
+	parallelRange(len(items), func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			leaves[i] = crypto.Keccak256(items[i])
+		}
+	})
+	t := Tree{leaves}
+
+	for {
+		level := t[len(t)-1]
+		if len(level) <= 1 { //root node
+			break
+		}
+		newLevel := make([][]byte, (len(level)+1)/2)
+		parallelRange(len(newLevel), func(lo, hi int) {
+			hashMergeRange(level, newLevel, lo, hi)
+		})
+		t = append(t, newLevel)
+	}
+	return t
+}
+
+// Below this many hashes per worker, the cost of
+// starting a goroutine outweighs the work it does.
+const minParallelChunk = 1024
+
+// Splits [0, n) into contiguous chunks and calls
+// f for each chunk on its own goroutine.
+func parallelRange(n int, f func(lo, hi int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if max := n / minParallelChunk; workers > max {
+		workers = max
+	}
+	if workers <= 1 {
+		f(0, n)
+		return
+	}
+
+	var (
+		wg    sync.WaitGroup
+		chunk = (n + workers - 1) / workers
+	)
+	for lo := 0; lo < n; lo += chunk {
+		hi := lo + chunk
+		if hi > n {
+			hi = n
+		}
+		wg.Add(1)
+		go func(lo, hi int) {
+			defer wg.Done()
+			f(lo, hi)
+		}(lo, hi)
+	}
+	wg.Wait()
+}
+
 func hashPair(a, b []byte) []byte {
 	if bytes.Compare(a, b) == -1 { // a < b
 		return crypto.Keccak256(a, b)
@@ -51,8 +113,16 @@ func hashPair(a, b []byte) []byte {
 // pair with a hash function creating a new level that
 // is half the size of the level.
 func hashMerge(level [][]byte) [][]byte {
-	newLevel := make([][]byte, 0, (len(level)+1)/2)
-	for i := 0; i < len(level); i += 2 {
+	newLevel := make([][]byte, (len(level)+1)/2)
+	hashMergeRange(level, newLevel, 0, len(newLevel))
+	return newLevel
+}
+
+// Sets newLevel[lo:hi] by merging the
+// corresponding pairs in level.
+func hashMergeRange(level, newLevel [][]byte, lo, hi int) {
+	for j := lo; j < hi; j++ {
+		i := j * 2
 		switch {
 		case i+1 == len(level):
 			// In the case of a level with an odd number of nodes
@@ -62,12 +132,11 @@ func hashMerge(level [][]byte) [][]byte {
 			// thus leaving the level with an even number of nodes.
 			// We don't have that requirement yet and if one day we do
 			// this is the spot to change:
-			newLevel = append(newLevel, level[i])
+			newLevel[j] = level[i]
 		default:
-			newLevel = append(newLevel, hashPair(level[i], level[i+1]))
+			newLevel[j] = hashPair(level[i], level[i+1])
 		}
 	}
-	return newLevel
 }
 
 func (t Tree) Root() []byte {
@@ -86,6 +155,38 @@ func (t Tree) Index(target []byte) int {
 	return -1
 }
 
+// A LeafIndex maps the hash of each leaf to its position
+// in the tree. It is built once using [Tree.LeafIndex] and
+// makes lookups constant time instead of a linear scan
+// over the leaves.
+type LeafIndex map[[32]byte]int
+
+// Returns a LeafIndex for the leaves in the tree.
+// If a leaf appears more than once, the first position is kept
+// so that results match [Tree.Index].
+func (t Tree) LeafIndex() LeafIndex {
+	idx := make(LeafIndex, len(t[0]))
+	for i, h := range t[0] {
+		var k [32]byte
+		copy(k[:], h)
+		if _, ok := idx[k]; !ok {
+			idx[k] = i
+		}
+	}
+	return idx
+}
+
+// Returns the index of the target leaf in the tree.
+// If the target is not a leaf in the tree, returns -1.
+func (li LeafIndex) Index(target []byte) int {
+	var k [32]byte
+	copy(k[:], crypto.Keccak256(target))
+	if i, ok := li[k]; ok {
+		return i
+	}
+	return -1
+}
+
 // Returns a list of hashes such that
 // cumulatively hashing the list pairwise
 // will yield the root hash of the tree. Example:
@@ -135,9 +236,15 @@ func (t Tree) LeafProofs() [][][]byte {
 // Cumulatively hashes the list pairwise starting with
 // (target, proof[0]). Finally, the cumulative hash is compared with the root.
 func Valid(root []byte, proof [][]byte, target []byte) bool {
+	return bytes.Equal(ProofRoot(proof, target), root)
+}
+
+// Returns the root that the proof yields for the target
+// leaf. Useful for diagnosing a proof that isn't [Valid].
+func ProofRoot(proof [][]byte, target []byte) []byte {
 	target = crypto.Keccak256(target)
 	for i := range proof {
 		target = hashPair(target, proof[i])
 	}
-	return bytes.Equal(target, root)
+	return target
 }
@@ -64,6 +64,29 @@ func TestRoot(t *testing.T) {
 	}
 }
 
+func TestNewParallel(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 1024, 2049, 10001} {
+		var leaves [][]byte
+		for i := 0; i < n; i++ {
+			leaves = append(leaves, []byte{byte(i), byte(i >> 8)})
+		}
+		want, got := New(leaves), NewParallel(leaves)
+		if len(got) != len(want) {
+			t.Fatalf("n=%d: expected %d levels, got %d", n, len(want), len(got))
+		}
+		for i := range want {
+			if len(got[i]) != len(want[i]) {
+				t.Fatalf("n=%d: expected %d nodes at level %d, got %d", n, len(want[i]), i, len(got[i]))
+			}
+			for j := range want[i] {
+				if !bytes.Equal(got[i][j], want[i][j]) {
+					t.Errorf("n=%d: level %d node %d differs", n, i, j)
+				}
+			}
+		}
+	}
+}
+
 func TestProof(t *testing.T) {
 	cases := []struct {
 		leaves [][]byte
@@ -122,6 +145,64 @@ func TestMissingIndex(t *testing.T) {
 	}
 }
 
+func TestLeafIndex(t *testing.T) {
+	leaves := [][]byte{
+		[]byte("a"),
+		[]byte("b"),
+		[]byte("c"),
+		[]byte("b"),
+		[]byte("e"),
+	}
+	mt := New(leaves)
+	li := mt.LeafIndex()
+	for _, l := range append(leaves, []byte("f")) {
+		got, want := li.Index(l), mt.Index(l)
+		if got != want {
+			t.Errorf("incorrect index for %s, expected %d, got %d", l, want, got)
+		}
+	}
+}
+
+func TestBinary(t *testing.T) {
+	for n := 2; n < 10; n++ {
+		var leaves [][]byte
+		for i := 0; i < n; i++ {
+			leaves = append(leaves, []byte{byte(i)})
+		}
+		mt := New(leaves)
+		b, err := mt.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got Tree
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(mt) {
+			t.Fatalf("expected %d levels, got %d", len(mt), len(got))
+		}
+		for i := range mt {
+			for j := range mt[i] {
+				if !bytes.Equal(got[i][j], mt[i][j]) {
+					t.Errorf("level %d node %d differs", i, j)
+				}
+			}
+		}
+
+		for _, bad := range [][]byte{
+			nil,
+			append([]byte{binaryVersion + 1}, b[1:]...),
+			b[:len(b)-1],
+			append(b, 0),
+		} {
+			if err := got.UnmarshalBinary(bad); err == nil {
+				t.Error("expected error for malformed tree")
+			}
+		}
+	}
+}
+
 func BenchmarkNew(b *testing.B) {
 	var leaves [][]byte
 	for i := 0; i < 50000; i++ {
@@ -133,6 +214,22 @@ func BenchmarkNew(b *testing.B) {
 	}
 }
 
+func benchmarkNew(b *testing.B, n int, f func([][]byte) Tree) {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = []byte{byte(i), byte(i >> 8), byte(i >> 16)}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f(leaves)
+	}
+}
+
+func BenchmarkNew100k(b *testing.B)         { benchmarkNew(b, 100000, New) }
+func BenchmarkNewParallel100k(b *testing.B) { benchmarkNew(b, 100000, NewParallel) }
+func BenchmarkNew1M(b *testing.B)           { benchmarkNew(b, 1000000, New) }
+func BenchmarkNewParallel1M(b *testing.B)   { benchmarkNew(b, 1000000, NewParallel) }
+
 func BenchmarkProof(b *testing.B) {
 	var leaves [][]byte
 	for i := 0; i < 50000; i++ {
@@ -162,3 +259,45 @@ func BenchmarkProofs(b *testing.B) {
 		mt.LeafProofs()
 	}
 }
+
+func BenchmarkIndex(b *testing.B) {
+	var leaves [][]byte
+	for i := 0; i < 50000; i++ {
+		leaves = append(leaves, []byte{byte(i), byte(i >> 8)})
+	}
+	mt := New(leaves)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mt.Index(leaves[len(leaves)-1])
+	}
+}
+
+func BenchmarkLeafIndex(b *testing.B) {
+	var leaves [][]byte
+	for i := 0; i < 50000; i++ {
+		leaves = append(leaves, []byte{byte(i), byte(i >> 8)})
+	}
+	li := New(leaves).LeafIndex()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		li.Index(leaves[len(leaves)-1])
+	}
+}
+
+func BenchmarkUnmarshalBinary(b *testing.B) {
+	var leaves [][]byte
+	for i := 0; i < 50000; i++ {
+		leaves = append(leaves, []byte{byte(i)})
+	}
+	data, err := New(leaves).MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var t Tree
+		if err := t.UnmarshalBinary(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}